@@ -0,0 +1,31 @@
+-- Example collect script for the -script-dir option
+-- The settings below are read by the exporter, all of them are optional.
+-- prefix: queues
+-- db: 0
+-- timeout: 500ms
+--
+-- The script returns a JSON encoded list of metrics, every metric has a name, a type (gauge or counter),
+-- an optional help text, optional labels and a value. The name is prefixed with the namespace and the prefix,
+-- e.g. redis_queues_length{queue="orders"}.
+-- More info about Redis Lua scripting: https://redis.io/commands/eval
+
+local result = {}
+
+for _, queue in ipairs({"orders", "emails"}) do
+    table.insert(result, {
+        name = "length",
+        type = "gauge",
+        help = "Number of jobs waiting in the queue",
+        labels = {queue = queue},
+        value = redis.call("LLEN", "queue:" .. queue),
+    })
+end
+
+local processed = redis.call("GET", "stats:jobs_processed")
+table.insert(result, {
+    name = "jobs_processed_total",
+    type = "counter",
+    value = tonumber(processed) or 0,
+})
+
+return cjson.encode(result)
@@ -0,0 +1,278 @@
+package exporter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+type aclLogEntry struct {
+	count    int64
+	reason   string
+	context  string
+	object   string
+	username string
+	// only available since Redis 7.2
+	entryID          int64
+	timestampCreated int64
+}
+
+// id identifies an ACL LOG entry across scrapes, Redis aggregates similar denials into one entry and
+// increments its count so the same entry shows up again and again. Before Redis 7.2 there is no entry id
+// and different entries with the same reason, context, object and username share the id.
+func (l aclLogEntry) id() string {
+	if l.entryID >= 0 {
+		return fmt.Sprintf("%d-%d", l.entryID, l.timestampCreated)
+	}
+	return strings.Join([]string{l.reason, l.context, l.object, l.username}, "\x00")
+}
+
+type aclDenialKey struct {
+	reason   string
+	context  string
+	username string
+	object   string
+}
+
+// aclLogState keeps track of the ACL LOG entries seen during the previous scrape and the
+// counters of the denials across scrapes
+type aclLogState struct {
+	seen      map[string]int64
+	objects   map[string]bool
+	usernames map[string]bool
+	denials   map[aclDenialKey]float64
+}
+
+func newACLLogState() *aclLogState {
+	return &aclLogState{
+		seen:      map[string]int64{},
+		objects:   map[string]bool{},
+		usernames: map[string]bool{},
+		denials:   map[aclDenialKey]float64{},
+	}
+}
+
+// update adds the denials that happened since the last scrape. Only up to maxObjects distinct objects and
+// maxUsernames distinct usernames (which are chosen by the clients for failed AUTHs) are tracked, all further
+// ones are accounted to "overflow".
+func (s *aclLogState) update(entries []aclLogEntry, maxObjects int64, maxUsernames int64) {
+	// The entries are newest first. Without entry ids (before Redis 7.2) entries with the same attributes share
+	// the id and only the newest of them can still get more denials, so its count is the one that's tracked. The
+	// older ones are only counted when the id shows up for the first time, they can drop out of the log later on.
+	seen := make(map[string]int64, len(entries))
+	totals := make(map[string]int64, len(entries))
+	var ids []string
+	firstEntries := map[string]aclLogEntry{}
+	for _, l := range entries {
+		id := l.id()
+		if _, ok := seen[id]; !ok {
+			ids = append(ids, id)
+			firstEntries[id] = l
+			seen[id] = l.count
+		}
+		totals[id] += l.count
+	}
+
+	for _, id := range ids {
+		l := firstEntries[id]
+		delta := totals[id]
+		if prev, ok := s.seen[id]; ok {
+			// a smaller count is a new entry (or the log was reset) that only has new denials
+			delta = seen[id]
+			if prev <= delta {
+				delta -= prev
+			}
+		}
+		if delta == 0 {
+			continue
+		}
+
+		object := limitDistinct(s.objects, l.object, maxObjects)
+		username := limitDistinct(s.usernames, l.username, maxUsernames)
+		s.denials[aclDenialKey{reason: l.reason, context: l.context, username: username, object: object}] += float64(delta)
+	}
+	s.seen = seen
+}
+
+// limitDistinct returns val if it's tracked already or there are less than max values, "overflow" otherwise
+func limitDistinct(tracked map[string]bool, val string, max int64) string {
+	if tracked[val] {
+		return val
+	}
+	if int64(len(tracked)) >= max {
+		return "overflow"
+	}
+	tracked[val] = true
+	return val
+}
+
+func parseACLLogEntry(reply interface{}) (l aclLogEntry, ok bool) {
+	fields, err := redis.Values(reply, nil)
+	if err != nil || len(fields)%2 != 0 {
+		return l, false
+	}
+	l.entryID = -1
+	for i := 0; i < len(fields); i += 2 {
+		name, err := redis.String(fields[i], nil)
+		if err != nil {
+			return l, false
+		}
+		switch name {
+		case "count":
+			l.count, err = redis.Int64(fields[i+1], nil)
+		case "reason":
+			l.reason, err = redis.String(fields[i+1], nil)
+		case "context":
+			l.context, err = redis.String(fields[i+1], nil)
+		case "object":
+			l.object, err = redis.String(fields[i+1], nil)
+		case "username":
+			l.username, err = redis.String(fields[i+1], nil)
+		case "entry-id":
+			l.entryID, err = redis.Int64(fields[i+1], nil)
+		case "timestamp-created":
+			l.timestampCreated, err = redis.Int64(fields[i+1], nil)
+		}
+		if err != nil {
+			return l, false
+		}
+	}
+	return l, l.reason != ""
+}
+
+func (e *Exporter) extractACLLogMetrics(ch chan<- prometheus.Metric, c redis.Conn) {
+	reply, err := redis.Values(doRedisCmd(c, "ACL", "LOG", e.options.ACLLogCount))
+	if err != nil {
+		log.Errorf("ACL LOG err: %s", err)
+		return
+	}
+
+	entries := make([]aclLogEntry, 0, len(reply))
+	for _, r := range reply {
+		l, ok := parseACLLogEntry(r)
+		if !ok {
+			log.Debugf("Invalid ACL LOG entry: %#v", r)
+			continue
+		}
+		entries = append(entries, l)
+	}
+
+	if e.aclLog == nil {
+		e.aclLog = newACLLogState()
+	}
+	e.aclLog.update(entries, e.options.ACLLogMaxObjects, e.options.ACLLogMaxUsernames)
+
+	for k, cnt := range e.aclLog.denials {
+		e.registerConstMetric(ch, "acl_denials_total", cnt, prometheus.CounterValue, k.reason, k.context, k.username, k.object)
+	}
+}
+
+type aclUser struct {
+	enabled     bool
+	nopass      bool
+	passwords   int
+	allKeys     bool
+	allCommands bool
+	selectors   int
+}
+
+// parseACLGetUser parses the reply of ACL GETUSER, the password hashes are only counted.
+// Redis 6 reports allkeys/allcommands as flags while Redis 7 only reports the key patterns and commands.
+func parseACLGetUser(reply interface{}) (u aclUser, ok bool) {
+	fields, err := redis.Values(reply, nil)
+	if err != nil || len(fields)%2 != 0 {
+		return u, false
+	}
+	for i := 0; i < len(fields); i += 2 {
+		name, err := redis.String(fields[i], nil)
+		if err != nil {
+			return u, false
+		}
+		switch name {
+		case "flags":
+			flags, _ := redis.Strings(fields[i+1], nil)
+			for _, f := range flags {
+				switch f {
+				case "on":
+					u.enabled = true
+				case "nopass":
+					u.nopass = true
+				case "allkeys":
+					u.allKeys = true
+				case "allcommands":
+					u.allCommands = true
+				}
+			}
+		case "passwords":
+			passwords, _ := redis.Values(fields[i+1], nil)
+			u.passwords = len(passwords)
+		case "keys":
+			// a string with Redis 7, a list of patterns with Redis 6
+			keys, err := redis.String(fields[i+1], nil)
+			if err != nil {
+				patterns, _ := redis.Strings(fields[i+1], nil)
+				keys = strings.Join(patterns, " ")
+			}
+			for _, k := range strings.Fields(keys) {
+				if k == "~*" || k == "%RW~*" || k == "allkeys" {
+					u.allKeys = true
+				}
+			}
+		case "commands":
+			commands, _ := redis.String(fields[i+1], nil)
+			if tokens := strings.Fields(commands); len(tokens) > 0 && tokens[0] == "+@all" {
+				u.allCommands = true
+				for _, t := range tokens[1:] {
+					if strings.HasPrefix(t, "-") {
+						u.allCommands = false
+					}
+				}
+			}
+		case "selectors":
+			selectors, _ := redis.Values(fields[i+1], nil)
+			u.selectors = len(selectors)
+		}
+	}
+	return u, true
+}
+
+func boolToFloat64(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// extractACLUserMetrics uses ACL USERS and ACL GETUSER instead of ACL LIST so the password hashes
+// never need to be parsed
+func (e *Exporter) extractACLUserMetrics(ch chan<- prometheus.Metric, c redis.Conn) {
+	users, err := redis.Strings(doRedisCmd(c, "ACL", "USERS"))
+	if err != nil {
+		log.Errorf("ACL USERS err: %s", err)
+		return
+	}
+
+	for _, name := range users {
+		reply, err := doRedisCmd(c, "ACL", "GETUSER", name)
+		if err != nil || reply == nil {
+			// the user might have been deleted in the meantime
+			log.Debugf("ACL GETUSER %s err: %s", name, err)
+			continue
+		}
+		u, ok := parseACLGetUser(reply)
+		if !ok {
+			log.Errorf("Invalid ACL GETUSER reply for user %s", name)
+			continue
+		}
+
+		e.registerConstMetricGauge(ch, "acl_user_enabled", boolToFloat64(u.enabled), name)
+		e.registerConstMetricGauge(ch, "acl_user_nopass", boolToFloat64(u.nopass), name)
+		e.registerConstMetricGauge(ch, "acl_user_passwords", float64(u.passwords), name)
+		e.registerConstMetricGauge(ch, "acl_user_allkeys", boolToFloat64(u.allKeys), name)
+		e.registerConstMetricGauge(ch, "acl_user_allcommands", boolToFloat64(u.allCommands), name)
+		e.registerConstMetricGauge(ch, "acl_user_selectors", float64(u.selectors), name)
+	}
+}
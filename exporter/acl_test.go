@@ -0,0 +1,242 @@
+package exporter
+
+import (
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestParseACLLogEntry(t *testing.T) {
+	reply := []interface{}{
+		[]byte("count"), int64(3),
+		[]byte("reason"), []byte("command"),
+		[]byte("context"), []byte("toplevel"),
+		[]byte("object"), []byte("flushall"),
+		[]byte("username"), []byte("app"),
+		[]byte("age-seconds"), []byte("1.5"),
+		[]byte("client-info"), []byte("id=3 addr=127.0.0.1:56788"),
+		[]byte("entry-id"), int64(7),
+		[]byte("timestamp-created"), int64(1675361492408),
+		[]byte("timestamp-last-updated"), int64(1675361492410),
+	}
+	l, ok := parseACLLogEntry(reply)
+	if !ok {
+		t.Fatalf("parseACLLogEntry() failed")
+	}
+	if l.count != 3 || l.reason != "command" || l.context != "toplevel" || l.object != "flushall" || l.username != "app" || l.entryID != 7 || l.timestampCreated != 1675361492408 {
+		t.Errorf("unexpected entry: %#v", l)
+	}
+
+	// Redis < 7.2 doesn't report entry ids
+	l, ok = parseACLLogEntry(reply[:12])
+	if !ok || l.entryID != -1 {
+		t.Errorf("unexpected entry without entry id: %#v", l)
+	}
+
+	if _, ok := parseACLLogEntry([]interface{}{[]byte("count")}); ok {
+		t.Errorf("expected invalid entry to fail")
+	}
+}
+
+func TestACLLogStateUpdate(t *testing.T) {
+	s := newACLLogState()
+	s.update([]aclLogEntry{
+		{count: 2, reason: "command", context: "toplevel", object: "flushall", username: "app", entryID: 1, timestampCreated: 100},
+		{count: 1, reason: "auth", context: "toplevel", object: "AUTH", username: "admin", entryID: 2, timestampCreated: 200},
+	}, 2, 10)
+	// entry 1 got two more denials, entry 2 is unchanged and entry 3 is new
+	s.update([]aclLogEntry{
+		{count: 4, reason: "command", context: "toplevel", object: "flushall", username: "app", entryID: 1, timestampCreated: 100},
+		{count: 1, reason: "auth", context: "toplevel", object: "AUTH", username: "admin", entryID: 2, timestampCreated: 200},
+		{count: 1, reason: "key", context: "multi", object: "secret", username: "app", entryID: 3, timestampCreated: 300},
+	}, 2, 10)
+	// after ACL LOG RESET
+	s.update([]aclLogEntry{
+		{count: 1, reason: "command", context: "toplevel", object: "flushall", username: "app", entryID: 4, timestampCreated: 400},
+	}, 2, 10)
+
+	want := map[aclDenialKey]float64{
+		{reason: "command", context: "toplevel", username: "app", object: "flushall"}: 5,
+		{reason: "auth", context: "toplevel", username: "admin", object: "AUTH"}:      1,
+		{reason: "key", context: "multi", username: "app", object: "overflow"}:        1,
+	}
+	if len(s.denials) != len(want) {
+		t.Fatalf("want: %#v, got: %#v", want, s.denials)
+	}
+	for k, v := range want {
+		if s.denials[k] != v {
+			t.Errorf("unexpected count for %#v, want: %f, got: %f", k, v, s.denials[k])
+		}
+	}
+
+	// failed AUTHs with random usernames are capped
+	s = newACLLogState()
+	s.update([]aclLogEntry{
+		{count: 1, reason: "auth", context: "toplevel", object: "AUTH", username: "admin", entryID: 1, timestampCreated: 100},
+		{count: 1, reason: "auth", context: "toplevel", object: "AUTH", username: "x1", entryID: 2, timestampCreated: 200},
+		{count: 1, reason: "auth", context: "toplevel", object: "AUTH", username: "x2", entryID: 3, timestampCreated: 300},
+	}, 10, 2)
+	want = map[aclDenialKey]float64{
+		{reason: "auth", context: "toplevel", username: "admin", object: "AUTH"}:    1,
+		{reason: "auth", context: "toplevel", username: "x1", object: "AUTH"}:       1,
+		{reason: "auth", context: "toplevel", username: "overflow", object: "AUTH"}: 1,
+	}
+	if !reflect.DeepEqual(s.denials, want) {
+		t.Errorf("want: %#v, got: %#v", want, s.denials)
+	}
+
+	// without entry ids (before Redis 7.2) the counts of entries with the same attributes are summed
+	s = newACLLogState()
+	entry := aclLogEntry{count: 2, reason: "command", context: "toplevel", object: "flushall", username: "app", entryID: -1}
+	newer := entry
+	newer.count = 3
+	s.update([]aclLogEntry{newer, entry}, 10, 10)
+	newer.count = 4
+	s.update([]aclLogEntry{newer, entry}, 10, 10)
+	if got := s.denials[aclDenialKey{reason: "command", context: "toplevel", username: "app", object: "flushall"}]; got != 6 {
+		t.Errorf("want 6 denials, got %f", got)
+	}
+	// the older entry dropped out of the log, that isn't a reset
+	s.update([]aclLogEntry{newer}, 10, 10)
+	if got := s.denials[aclDenialKey{reason: "command", context: "toplevel", username: "app", object: "flushall"}]; got != 6 {
+		t.Errorf("want 6 denials after the older entry dropped out of the log, got %f", got)
+	}
+	newer.count = 5
+	s.update([]aclLogEntry{newer}, 10, 10)
+	// after ACL LOG RESET
+	entry.count = 1
+	s.update([]aclLogEntry{entry}, 10, 10)
+	if got := s.denials[aclDenialKey{reason: "command", context: "toplevel", username: "app", object: "flushall"}]; got != 8 {
+		t.Errorf("want 8 denials, got %f", got)
+	}
+}
+
+func TestACLLogMetrics(t *testing.T) {
+	if os.Getenv("TEST_REDIS_URI") == "" {
+		t.Skipf("TEST_REDIS_URI not set - skipping")
+	}
+	addr := os.Getenv("TEST_REDIS_URI")
+
+	c, err := redis.DialURL(addr)
+	if err != nil {
+		t.Fatalf("Couldn't connect to %#v: %#v", addr, err)
+	}
+	defer c.Close()
+
+	if _, err := doRedisCmd(c, "ACL", "LOG", "RESET"); err != nil {
+		t.Skipf("ACL LOG not supported: %s", err)
+	}
+	if _, err := doRedisCmd(c, "AUTH", "exporter_test_user", "wrong-password"); err == nil {
+		t.Fatalf("expected AUTH to fail")
+	}
+
+	e, _ := NewRedisExporter(addr, Options{Namespace: "test", Registry: prometheus.NewRegistry(), ExportACLLog: true})
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+
+	want := `test_acl_denials_total{context="toplevel",object="AUTH",reason="auth",username="exporter_test_user"} 1`
+	if body := downloadURL(t, ts.URL+"/metrics"); !strings.Contains(body, want) {
+		t.Errorf("want metrics to include %s, have:\n%s", want, body)
+	}
+
+	// the entry was seen already so the counter doesn't change
+	if body := downloadURL(t, ts.URL+"/metrics"); !strings.Contains(body, want) {
+		t.Errorf("want metrics to include %s, have:\n%s", want, body)
+	}
+}
+
+func TestParseACLGetUser(t *testing.T) {
+	for _, tst := range []struct {
+		name  string
+		reply []interface{}
+		want  aclUser
+	}{
+		{
+			name: "redis 7 default user",
+			reply: []interface{}{
+				[]byte("flags"), []interface{}{[]byte("on"), []byte("nopass"), []byte("sanitize-payload")},
+				[]byte("passwords"), []interface{}{},
+				[]byte("commands"), []byte("+@all"),
+				[]byte("keys"), []byte("~*"),
+				[]byte("channels"), []byte("&*"),
+				[]byte("selectors"), []interface{}{},
+			},
+			want: aclUser{enabled: true, nopass: true, allKeys: true, allCommands: true},
+		},
+		{
+			name: "redis 7 restricted user with selector",
+			reply: []interface{}{
+				[]byte("flags"), []interface{}{[]byte("off")},
+				[]byte("passwords"), []interface{}{[]byte("5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8"), []byte("a665a45920422f9d417e4867efdc4fb8a04a1f3fff1fa07e998e86f7f7a27ae3")},
+				[]byte("commands"), []byte("+@all -flushall"),
+				[]byte("keys"), []byte("~app:*"),
+				[]byte("channels"), []byte(""),
+				[]byte("selectors"), []interface{}{
+					[]interface{}{[]byte("commands"), []byte("-@all +get"), []byte("keys"), []byte("~*"), []byte("channels"), []byte("")},
+				},
+			},
+			want: aclUser{passwords: 2, selectors: 1},
+		},
+		{
+			name: "redis 6 flags",
+			reply: []interface{}{
+				[]byte("flags"), []interface{}{[]byte("on"), []byte("allkeys"), []byte("allchannels"), []byte("allcommands")},
+				[]byte("passwords"), []interface{}{[]byte("5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8")},
+				[]byte("commands"), []byte("+@all"),
+				[]byte("keys"), []interface{}{[]byte("*")},
+				[]byte("channels"), []interface{}{[]byte("*")},
+			},
+			want: aclUser{enabled: true, passwords: 1, allKeys: true, allCommands: true},
+		},
+	} {
+		t.Run(tst.name, func(t *testing.T) {
+			got, ok := parseACLGetUser(tst.reply)
+			if !ok {
+				t.Fatalf("parseACLGetUser() failed")
+			}
+			if got != tst.want {
+				t.Errorf("want: %#v, got: %#v", tst.want, got)
+			}
+		})
+	}
+}
+
+func TestACLUserMetrics(t *testing.T) {
+	if os.Getenv("TEST_REDIS_URI") == "" {
+		t.Skipf("TEST_REDIS_URI not set - skipping")
+	}
+	addr := os.Getenv("TEST_REDIS_URI")
+
+	c, err := redis.DialURL(addr)
+	if err != nil {
+		t.Fatalf("Couldn't connect to %#v: %#v", addr, err)
+	}
+	defer c.Close()
+
+	if _, err := doRedisCmd(c, "ACL", "SETUSER", "exporter_test_user", "on", "nopass", "~app:*", "+get"); err != nil {
+		t.Skipf("ACL SETUSER not supported: %s", err)
+	}
+	defer doRedisCmd(c, "ACL", "DELUSER", "exporter_test_user")
+
+	e, _ := NewRedisExporter(addr, Options{Namespace: "test", Registry: prometheus.NewRegistry(), ExportACLUsers: true})
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+
+	body := downloadURL(t, ts.URL+"/metrics")
+	for _, want := range []string{
+		`test_acl_user_enabled{username="exporter_test_user"} 1`,
+		`test_acl_user_nopass{username="exporter_test_user"} 1`,
+		`test_acl_user_passwords{username="exporter_test_user"} 0`,
+		`test_acl_user_allkeys{username="exporter_test_user"} 0`,
+		`test_acl_user_allcommands{username="exporter_test_user"} 0`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("want metrics to include %s, have:\n%s", want, body)
+		}
+	}
+}
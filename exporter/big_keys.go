@@ -0,0 +1,105 @@
+package exporter
+
+import (
+	"fmt"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// upper bounds (in bytes) of the buckets used for the big_keys_sampled_memory_usage_bytes histogram
+var bigKeysMemoryBuckets = prometheus.ExponentialBuckets(64, 4, 10)
+
+type bigKeysDBState struct {
+	*sampledTopKeys
+	// the histograms of the current pass over the keyspace and of the last completed one
+	histograms          map[string]*sampleHistogram
+	completedHistograms map[string]*sampleHistogram
+}
+
+func newBigKeysDBState() *bigKeysDBState {
+	return &bigKeysDBState{
+		sampledTopKeys: newSampledTopKeys(),
+		histograms:     map[string]*sampleHistogram{},
+	}
+}
+
+// setCursor starts new histograms once a pass over the keyspace is complete so every key is only
+// observed once per histogram
+func (s *bigKeysDBState) setCursor(cursor int64) {
+	s.sampledTopKeys.setCursor(cursor)
+	if cursor == 0 {
+		s.completedHistograms = s.histograms
+		s.histograms = map[string]*sampleHistogram{}
+	}
+}
+
+// publishedHistograms returns the histograms of the last completed pass, or the ones of the current
+// pass until the first pass is complete
+func (s *bigKeysDBState) publishedHistograms() map[string]*sampleHistogram {
+	if s.completedHistograms != nil {
+		return s.completedHistograms
+	}
+	return s.histograms
+}
+
+func (s *bigKeysDBState) add(k sampledKey) {
+	h, ok := s.histograms[k.keyType]
+	if !ok {
+		h = newSampleHistogram(bigKeysMemoryBuckets)
+		s.histograms[k.keyType] = h
+	}
+	h.observe(bigKeysMemoryBuckets, k.memoryUsage)
+
+	s.sampledTopKeys.add(k)
+}
+
+// trim only keeps the topN keys with the highest memory usage per type
+func (s *bigKeysDBState) trim(topN int64) {
+	s.sampledTopKeys.trim(
+		topN,
+		func(k sampledKey) string { return k.keyType },
+		func(k sampledKey) float64 { return k.memoryUsage },
+	)
+}
+
+func (e *Exporter) extractBigKeysMetrics(ch chan<- prometheus.Metric, c redis.Conn, dbCount int) {
+	if e.bigKeys == nil {
+		e.bigKeys = map[int]*bigKeysDBState{}
+	}
+
+	for db := 0; db < dbCount; db++ {
+		if _, err := doRedisCmd(c, "SELECT", db); err != nil {
+			log.Errorf("Couldn't select database %d when sampling big keys.", db)
+			continue
+		}
+		if size, err := redis.Int64(doRedisCmd(c, "DBSIZE")); err != nil || size == 0 {
+			delete(e.bigKeys, db)
+			continue
+		}
+
+		state, ok := e.bigKeys[db]
+		if !ok {
+			state = newBigKeysDBState()
+			e.bigKeys[db] = state
+		}
+
+		cursor, err := sampleKeys(c, state.cursor, e.newKeySampleBudget(), []string{sampleAttrMemory, sampleAttrSize}, nil, state.add)
+		if err != nil {
+			log.Errorf("Error sampling big keys for db %d: %s", db, err)
+			continue
+		}
+		state.setCursor(cursor)
+		state.trim(e.options.BigKeysTopN)
+
+		dbLabel := fmt.Sprintf("db%d", db)
+		for _, entry := range state.top {
+			e.registerConstMetricGauge(ch, "big_key_memory_usage_bytes", entry.memoryUsage, dbLabel, entry.keyType, entry.key)
+			e.registerConstMetricGauge(ch, "big_key_size", entry.size, dbLabel, entry.keyType, entry.key)
+		}
+		for keyType, h := range state.publishedHistograms() {
+			e.registerConstHistogram(ch, "big_keys_sampled_memory_usage_bytes", h.count, h.sum, h.cumulativeBuckets(bigKeysMemoryBuckets), dbLabel, keyType)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package exporter
+
+import (
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestBigKeysDBState(t *testing.T) {
+	s := newBigKeysDBState()
+	for _, k := range []sampledKey{
+		{key: "a", keyType: "string", memoryUsage: 50, size: 10},
+		{key: "b", keyType: "string", memoryUsage: 500, size: 100},
+		{key: "c", keyType: "string", memoryUsage: 5000, size: 1000},
+		{key: "l", keyType: "list", memoryUsage: 100, size: 3},
+	} {
+		s.add(k)
+	}
+	s.trim(2)
+
+	if len(s.top) != 3 || s.top["a"] != nil || s.top["b"] == nil || s.top["c"] == nil || s.top["l"] == nil {
+		t.Errorf("expected keys b, c and l to be kept, got: %#v", s.top)
+	}
+
+	if h := s.histograms["string"]; h == nil || h.count != 3 || h.sum != 5550 {
+		t.Fatalf("unexpected histogram for strings: %#v", h)
+	}
+	buckets := s.histograms["string"].cumulativeBuckets(bigKeysMemoryBuckets)
+	if buckets[64] != 1 || buckets[1024] != 2 || buckets[16384] != 3 {
+		t.Errorf("unexpected cumulative buckets: %#v", buckets)
+	}
+
+	// "c" is seen again during the current pass, "b" and "l" are not so they're dropped once the pass completes
+	s.setCursor(0)
+	s.add(sampledKey{key: "c", keyType: "string", memoryUsage: 6000, size: 1200})
+	s.setCursor(42)
+	if len(s.top) != 3 {
+		t.Errorf("expected all keys to be kept until the pass is complete, got: %#v", s.top)
+	}
+	s.setCursor(0)
+	if len(s.top) != 1 || s.top["c"] == nil || s.top["c"].memoryUsage != 6000 {
+		t.Errorf("expected only key c to be kept, got: %#v", s.top)
+	}
+
+	// the histograms of the completed pass are published and the next pass starts over
+	if h := s.publishedHistograms()["string"]; h == nil || h.count != 1 || h.sum != 6000 {
+		t.Errorf("unexpected histogram of the completed pass: %#v", h)
+	}
+	if len(s.histograms) != 0 {
+		t.Errorf("expected empty histograms for the next pass, got: %#v", s.histograms)
+	}
+	s.add(sampledKey{key: "c", keyType: "string", memoryUsage: 6000, size: 1200})
+	if h := s.publishedHistograms()["string"]; h.count != 1 {
+		t.Errorf("expected the histogram of the completed pass until the next one is complete, got: %#v", h)
+	}
+}
+
+func TestBigKeysMetrics(t *testing.T) {
+	if os.Getenv("TEST_REDIS_URI") == "" {
+		t.Skipf("TEST_REDIS_URI not set - skipping")
+	}
+	addr := os.Getenv("TEST_REDIS_URI")
+
+	c, err := redis.DialURL(addr)
+	if err != nil {
+		t.Fatalf("Couldn't connect to %#v: %#v", addr, err)
+	}
+	defer c.Close()
+
+	setupDBKeys(t, addr)
+	defer deleteKeysFromDB(t, addr)
+
+	e, _ := NewRedisExporter(addr, Options{Namespace: "test", Registry: prometheus.NewRegistry(), CheckBigKeys: true, BigKeysTopN: 1})
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+
+	body := downloadURL(t, ts.URL+"/metrics")
+	for _, want := range []string{
+		`test_big_key_memory_usage_bytes{db="db11",key="beatles_list",type="list"}`,
+		`test_big_key_size{db="db11",key="beatles_list",type="list"} 4`,
+		`test_big_keys_sampled_memory_usage_bytes_count{db="db11",type="string"}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("want metrics to include %s, have:\n%s", want, body)
+		}
+	}
+
+	// BigKeysTopN is 1 so there's only one string key per db
+	stringKeys := 0
+	for _, l := range strings.Split(body, "\n") {
+		if strings.HasPrefix(l, `test_big_key_size{db="db11"`) && strings.Contains(l, `type="string"`) {
+			stringKeys++
+		}
+	}
+	if stringKeys != 1 {
+		t.Errorf("expected only the biggest string key, got %d keys:\n%s", stringKeys, body)
+	}
+}
@@ -2,6 +2,7 @@ package exporter
 
 import (
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -11,12 +12,31 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// upper bounds (in seconds) of the buckets used for the connected_clients_group_idle_seconds histogram
+var clientIdleBuckets = []float64{1, 10, 60, 300, 900, 3600, 21600, 86400}
+
+type clientGroupKey struct {
+	name, user, libName, libVer, flags, host string
+}
+
+type clientGroupMetrics struct {
+	connections int64
+	omem        float64
+	omemMax     float64
+	qbuf        float64
+	qbufMax     float64
+	totMem      float64
+	totMemMax   float64
+	idleSum     float64
+	idleBuckets []uint64
+}
+
 /*
 	Valid Examples
 	id=11 addr=127.0.0.1:63508 fd=8 name= age=6321 idle=6320 flags=N db=0 sub=0 psub=0 multi=-1 qbuf=0 qbuf-free=0 obl=0 oll=0 omem=0 events=r cmd=setex
 	id=14 addr=127.0.0.1:64958 fd=9 name= age=5 idle=0 flags=N db=0 sub=0 psub=0 multi=-1 qbuf=26 qbuf-free=32742 obl=0 oll=0 omem=0 events=r cmd=client
 */
-func parseClientListString(clientInfo string) ([]string, bool) {
+func parseClientListFields(clientInfo string) (map[string]string, bool) {
 	if matched, _ := regexp.MatchString(`^id=\d+ addr=\d+`, clientInfo); !matched {
 		return nil, false
 	}
@@ -29,6 +49,14 @@ func parseClientListString(clientInfo string) ([]string, bool) {
 		}
 		connectedClient[vPart[0]] = vPart[1]
 	}
+	return connectedClient, true
+}
+
+func parseClientListString(clientInfo string) ([]string, bool) {
+	connectedClient, ok := parseClientListFields(clientInfo)
+	if !ok {
+		return nil, false
+	}
 
 	createdAtTs, err := durationFieldToTimestamp(connectedClient["age"])
 	if err != nil {
@@ -71,6 +99,68 @@ func durationFieldToTimestamp(field string) (string, error) {
 	return strconv.FormatInt(time.Now().Unix()-parsed, 10), nil
 }
 
+// aggregateClientList groups the connections of a CLIENT LIST reply by name, user, library name and version,
+// flags and source host so the number of series doesn't grow with the number of connections.
+// Fields that don't exist in older Redis versions (e.g. user, lib-name, tot-mem) are treated as empty or zero.
+func aggregateClientList(reply string) map[clientGroupKey]*clientGroupMetrics {
+	groups := map[clientGroupKey]*clientGroupMetrics{}
+	for _, line := range strings.Split(reply, "\n") {
+		client, ok := parseClientListFields(strings.TrimSpace(line))
+		if !ok {
+			continue
+		}
+
+		idle, err := strconv.ParseFloat(client["idle"], 64)
+		if err != nil {
+			log.Debugf("could not parse idle field(%s): %s", client["idle"], err)
+			continue
+		}
+
+		host := client["addr"]
+		if idx := strings.LastIndex(host, ":"); idx > -1 {
+			host = host[:idx]
+		}
+
+		key := clientGroupKey{
+			name:    client["name"],
+			user:    client["user"],
+			libName: client["lib-name"],
+			libVer:  client["lib-ver"],
+			flags:   client["flags"],
+			host:    host,
+		}
+		g, ok := groups[key]
+		if !ok {
+			g = &clientGroupMetrics{idleBuckets: make([]uint64, len(clientIdleBuckets))}
+			groups[key] = g
+		}
+
+		omem, _ := strconv.ParseFloat(client["omem"], 64)
+		qbuf, _ := strconv.ParseFloat(client["qbuf"], 64)
+		totMem, _ := strconv.ParseFloat(client["tot-mem"], 64)
+
+		g.connections++
+		g.omem += omem
+		g.qbuf += qbuf
+		g.totMem += totMem
+		if omem > g.omemMax {
+			g.omemMax = omem
+		}
+		if qbuf > g.qbufMax {
+			g.qbufMax = qbuf
+		}
+		if totMem > g.totMemMax {
+			g.totMemMax = totMem
+		}
+
+		g.idleSum += idle
+		if idx := sort.SearchFloat64s(clientIdleBuckets, idle); idx < len(clientIdleBuckets) {
+			g.idleBuckets[idx]++
+		}
+	}
+	return groups
+}
+
 func (e *Exporter) extractConnectedClientMetrics(ch chan<- prometheus.Metric, c redis.Conn) {
 	reply, err := redis.String(doRedisCmd(c, "CLIENT", "LIST"))
 	if err != nil {
@@ -78,17 +168,41 @@ func (e *Exporter) extractConnectedClientMetrics(ch chan<- prometheus.Metric, c
 		return
 	}
 
-	for _, c := range strings.Split(reply, "\n") {
-		if lbls, ok := parseClientListString(c); ok {
+	if e.options.ExportClientList {
+		for _, c := range strings.Split(reply, "\n") {
+			if lbls, ok := parseClientListString(c); ok {
+
+				// port is the last item, we'll trim it if it's not needed
+				if !e.options.ExportClientsInclPort {
+					lbls = lbls[:len(lbls)-1]
+				}
+				e.registerConstMetricGauge(
+					ch, "connected_clients_details", 1.0,
+					lbls...,
+				)
+			}
+		}
+	}
+
+	if e.options.ExportClientListAggregated {
+		for k, g := range aggregateClientList(reply) {
+			lbls := []string{k.name, k.user, k.libName, k.libVer, k.flags, k.host}
+			e.registerConstMetricGauge(ch, "connected_clients_group_connections", float64(g.connections), lbls...)
+			e.registerConstMetricGauge(ch, "connected_clients_group_omem_bytes", g.omem, lbls...)
+			e.registerConstMetricGauge(ch, "connected_clients_group_omem_max_bytes", g.omemMax, lbls...)
+			e.registerConstMetricGauge(ch, "connected_clients_group_qbuf_bytes", g.qbuf, lbls...)
+			e.registerConstMetricGauge(ch, "connected_clients_group_qbuf_max_bytes", g.qbufMax, lbls...)
+			e.registerConstMetricGauge(ch, "connected_clients_group_tot_mem_bytes", g.totMem, lbls...)
+			e.registerConstMetricGauge(ch, "connected_clients_group_tot_mem_max_bytes", g.totMemMax, lbls...)
 
-			// port is the last item, we'll trim it if it's not needed
-			if !e.options.ExportClientsInclPort {
-				lbls = lbls[:len(lbls)-1]
+			// histogram buckets are cumulative
+			buckets := map[float64]uint64{}
+			var cnt uint64
+			for idx, upperBound := range clientIdleBuckets {
+				cnt += g.idleBuckets[idx]
+				buckets[upperBound] = cnt
 			}
-			e.registerConstMetricGauge(
-				ch, "connected_clients_details", 1.0,
-				lbls...,
-			)
+			e.registerConstHistogram(ch, "connected_clients_group_idle_seconds", uint64(g.connections), g.idleSum, buckets, lbls...)
 		}
 	}
 }
@@ -163,3 +163,67 @@ func TestExportClientListInclPort(t *testing.T) {
 		}
 	}
 }
+
+func TestAggregateClientList(t *testing.T) {
+	reply := "id=11 addr=10.0.0.1:63508 laddr=10.0.0.9:6379 fd=8 name=app age=6321 idle=5 flags=N db=0 sub=0 psub=0 multi=-1 qbuf=10 qbuf-free=0 obl=0 oll=0 omem=100 tot-mem=2000 events=r cmd=get user=default lib-name=redis-py lib-ver=5.0.1\n" +
+		"id=12 addr=10.0.0.1:63509 laddr=10.0.0.9:6379 fd=9 name=app age=6321 idle=700 flags=N db=0 sub=0 psub=0 multi=-1 qbuf=30 qbuf-free=0 obl=0 oll=0 omem=0 tot-mem=3000 events=r cmd=get user=default lib-name=redis-py lib-ver=5.0.1\n" +
+		"id=13 addr=10.0.0.2:61234 fd=10 name= age=5 idle=0 flags=N db=0 sub=0 psub=0 multi=-1 qbuf=26 qbuf-free=32742 obl=0 oll=0 omem=0 events=r cmd=client\n" +
+		"id=14 addr=10.0.0.2:61235 fd=11 name= age=5 idle=NOPE flags=N db=0 sub=0 psub=0 multi=-1 qbuf=26 qbuf-free=32742 obl=0 oll=0 omem=0 events=r cmd=client\n"
+
+	groups := aggregateClientList(reply)
+	if len(groups) != 2 {
+		t.Fatalf("expected 2 client groups, got: %d - %#v", len(groups), groups)
+	}
+
+	app, ok := groups[clientGroupKey{name: "app", user: "default", libName: "redis-py", libVer: "5.0.1", flags: "N", host: "10.0.0.1"}]
+	if !ok {
+		t.Fatalf("client group for app not found, got: %#v", groups)
+	}
+	if app.connections != 2 || app.omem != 100 || app.omemMax != 100 || app.qbuf != 40 || app.qbufMax != 30 || app.totMem != 5000 || app.totMemMax != 3000 {
+		t.Errorf("unexpected metrics for client group app: %#v", app)
+	}
+	if app.idleSum != 705 {
+		t.Errorf("expected idle sum of 705, got: %f", app.idleSum)
+	}
+	// idle=5 falls into the "10" bucket, idle=700 into the "900" bucket
+	if app.idleBuckets[1] != 1 || app.idleBuckets[4] != 1 {
+		t.Errorf("unexpected idle buckets: %#v", app.idleBuckets)
+	}
+
+	// the connection with the invalid idle field is skipped
+	if other := groups[clientGroupKey{flags: "N", host: "10.0.0.2"}]; other == nil || other.connections != 1 {
+		t.Errorf("unexpected metrics for unnamed client group: %#v", other)
+	}
+}
+
+func TestExportClientListAggregated(t *testing.T) {
+	for _, isAggregated := range []bool{true, false} {
+		e := getTestExporterWithOptions(Options{
+			Namespace: "test", Registry: prometheus.NewRegistry(),
+			ExportClientListAggregated: isAggregated,
+		})
+
+		chM := make(chan prometheus.Metric)
+		go func() {
+			e.Collect(chM)
+			close(chM)
+		}()
+
+		found := false
+		for m := range chM {
+			desc := m.Desc().String()
+			if strings.Contains(desc, "connected_clients_details") {
+				t.Errorf("connected_clients_details was *found* but only aggregated client metrics were expected")
+			}
+			if strings.Contains(desc, "connected_clients_group_connections") {
+				found = true
+			}
+		}
+
+		if isAggregated && !found {
+			t.Errorf("connected_clients_group_connections was *not* found in aggregated client list metrics but expected")
+		} else if !isAggregated && found {
+			t.Errorf("connected_clients_group_connections was *found* but *not* expected")
+		}
+	}
+}
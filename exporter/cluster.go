@@ -0,0 +1,291 @@
+package exporter
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// node flags that are exported as cluster_node_flag, "fail?" is reported as "pfail"
+var clusterNodeFlags = []string{"myself", "master", "slave", "fail", "pfail", "handshake", "noaddr", "nofailover"}
+
+type clusterNode struct {
+	id             string
+	address        string
+	masterID       string
+	flags          map[string]bool
+	pingSent       int64
+	pongRecv       int64
+	configEpoch    float64
+	linkState      string
+	slots          int64
+	migratingSlots int64
+	importingSlots int64
+}
+
+/*
+	valid examples:
+		07c37dfeb235213a872192d90877d0cd55635b91 127.0.0.1:30004@31004,hostname4 slave e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca 0 1426238317239 4 connected
+		e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca 127.0.0.1:30001@31001 myself,master - 0 0 1 connected 0-5460 [93->-292f8b365bb7edb5e285caf0b7e6ddc7265d2f4f]
+		292f8b365bb7edb5e285caf0b7e6ddc7265d2f4f 127.0.0.1:30003@31003 master - 0 1426238318243 3 connected 10923-16383 [93-<-e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca]
+*/
+func parseClusterNodeString(line string) (node clusterNode, ok bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 8 {
+		log.Debugf("parseClusterNodeString() invalid number of fields, got: %s", line)
+		return
+	}
+
+	node.id = fields[0]
+
+	// the address can be followed by the cluster bus port and the hostname, e.g. 127.0.0.1:30001@31001,hostname1
+	node.address = strings.SplitN(strings.SplitN(fields[1], ",", 2)[0], "@", 2)[0]
+
+	node.flags = map[string]bool{}
+	for _, f := range strings.Split(fields[2], ",") {
+		if f == "fail?" {
+			f = "pfail"
+		}
+		node.flags[f] = true
+	}
+
+	if fields[3] != "-" {
+		node.masterID = fields[3]
+	}
+
+	var err error
+	if node.pingSent, err = strconv.ParseInt(fields[4], 10, 64); err != nil {
+		log.Debugf("parseClusterNodeString() couldn't parse ping-sent, got: %s", fields[4])
+		return
+	}
+	if node.pongRecv, err = strconv.ParseInt(fields[5], 10, 64); err != nil {
+		log.Debugf("parseClusterNodeString() couldn't parse pong-recv, got: %s", fields[5])
+		return
+	}
+	if node.configEpoch, err = strconv.ParseFloat(fields[6], 64); err != nil {
+		log.Debugf("parseClusterNodeString() couldn't parse config-epoch, got: %s", fields[6])
+		return
+	}
+	node.linkState = fields[7]
+
+	for _, slot := range fields[8:] {
+		switch {
+		case strings.HasPrefix(slot, "[") && strings.Contains(slot, "->-"):
+			node.migratingSlots++
+		case strings.HasPrefix(slot, "[") && strings.Contains(slot, "-<-"):
+			node.importingSlots++
+		default:
+			frags := strings.SplitN(slot, "-", 2)
+			start, err := strconv.ParseInt(frags[0], 10, 64)
+			if err != nil {
+				log.Debugf("parseClusterNodeString() couldn't parse slot, got: %s", slot)
+				return
+			}
+			end := start
+			if len(frags) == 2 {
+				if end, err = strconv.ParseInt(frags[1], 10, 64); err != nil {
+					log.Debugf("parseClusterNodeString() couldn't parse slot range, got: %s", slot)
+					return
+				}
+			}
+			node.slots += end - start + 1
+		}
+	}
+
+	ok = true
+	return
+}
+
+func (e *Exporter) extractClusterNodesMetrics(ch chan<- prometheus.Metric, c redis.Conn) {
+	reply, err := redis.String(doRedisCmd(c, "CLUSTER", "NODES"))
+	if err != nil {
+		log.Errorf("CLUSTER NODES err: %s", err)
+		return
+	}
+
+	now := time.Now()
+	for _, line := range strings.Split(reply, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		node, ok := parseClusterNodeString(line)
+		if !ok {
+			continue
+		}
+
+		lbls := []string{node.id, node.address, node.masterID}
+		for _, f := range clusterNodeFlags {
+			val := 0.0
+			if node.flags[f] {
+				val = 1
+			}
+			e.registerConstMetricGauge(ch, "cluster_node_flag", val, append(lbls, f)...)
+		}
+
+		linkConnected := 0.0
+		if node.linkState == "connected" {
+			linkConnected = 1
+		}
+		e.registerConstMetricGauge(ch, "cluster_node_link_connected", linkConnected, lbls...)
+
+		// a ping-sent value of zero means there is no pending ping
+		pingAge := 0.0
+		if node.pingSent > 0 {
+			pingAge = now.Sub(time.Unix(0, node.pingSent*int64(time.Millisecond))).Seconds()
+		}
+		e.registerConstMetricGauge(ch, "cluster_node_ping_sent_age_seconds", pingAge, lbls...)
+
+		// the "myself" node never receives pongs from itself
+		if node.pongRecv > 0 {
+			pongAge := now.Sub(time.Unix(0, node.pongRecv*int64(time.Millisecond))).Seconds()
+			e.registerConstMetricGauge(ch, "cluster_node_pong_received_age_seconds", pongAge, lbls...)
+		}
+
+		e.registerConstMetricGauge(ch, "cluster_node_config_epoch", node.configEpoch, lbls...)
+		e.registerConstMetricGauge(ch, "cluster_node_slots", float64(node.slots), lbls...)
+		e.registerConstMetricGauge(ch, "cluster_node_migrating_slots", float64(node.migratingSlots), lbls...)
+		e.registerConstMetricGauge(ch, "cluster_node_importing_slots", float64(node.importingSlots), lbls...)
+	}
+}
+
+type clusterSlotStats struct {
+	slot            int64
+	keyCount        float64
+	cpuUsec         float64
+	networkBytesIn  float64
+	networkBytesOut float64
+}
+
+/*
+	reply of CLUSTER SLOT-STATS, cpu-usec and network-bytes-* are only included when
+	cluster-slot-stats-enabled is set to yes:
+		1) 1) (integer) 12426
+		   2) 1) "key-count"
+		      2) (integer) 45
+		      3) "cpu-usec"
+		      4) (integer) 3012
+*/
+func parseClusterSlotStats(reply []interface{}) ([]clusterSlotStats, error) {
+	res := make([]clusterSlotStats, 0, len(reply))
+	for _, entry := range reply {
+		vals, err := redis.Values(entry, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(vals) != 2 {
+			return nil, fmt.Errorf("invalid CLUSTER SLOT-STATS entry: %#v", vals)
+		}
+
+		slot, err := redis.Int64(vals[0], nil)
+		if err != nil {
+			return nil, err
+		}
+		stats, err := redis.Int64Map(vals[1], nil)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, clusterSlotStats{
+			slot:            slot,
+			keyCount:        float64(stats["key-count"]),
+			cpuUsec:         float64(stats["cpu-usec"]),
+			networkBytesIn:  float64(stats["network-bytes-in"]),
+			networkBytesOut: float64(stats["network-bytes-out"]),
+		})
+	}
+	return res, nil
+}
+
+// topClusterSlots returns up to limit slots with the highest value as returned by fn, ignoring slots where it's zero.
+func topClusterSlots(stats []clusterSlotStats, limit int64, fn func(s clusterSlotStats) float64) []clusterSlotStats {
+	sorted := make([]clusterSlotStats, 0, len(stats))
+	for _, s := range stats {
+		if fn(s) > 0 {
+			sorted = append(sorted, s)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if fn(sorted[i]) == fn(sorted[j]) {
+			return sorted[i].slot < sorted[j].slot
+		}
+		return fn(sorted[i]) > fn(sorted[j])
+	})
+	if int64(len(sorted)) > limit {
+		sorted = sorted[:limit]
+	}
+	return sorted
+}
+
+func (e *Exporter) extractClusterSlotStatsMetrics(ch chan<- prometheus.Metric, c redis.Conn) {
+	nodeID, err := redis.String(doRedisCmd(c, "CLUSTER", "MYID"))
+	if err != nil {
+		log.Errorf("CLUSTER MYID err: %s", err)
+		return
+	}
+
+	// The full SLOTSRANGE is used instead of one ORDERBY ... LIMIT per metric because the per-node
+	// aggregates and the CPU share of the top slots need the stats of all slots anyway. It's safe to
+	// request on every scrape: only the slots owned by this node are returned, that's at most 16384
+	// entries of four integers (a few hundred KB), and ORDERBY has to go over all slots on the server
+	// too. Only the top slots per metric are exported, so there are at most 4 * cluster-slot-stats-limit
+	// slot series.
+	reply, err := redis.Values(doRedisCmd(c, "CLUSTER", "SLOT-STATS", "SLOTSRANGE", 0, 16383))
+	if err != nil {
+		log.Errorf("CLUSTER SLOT-STATS err: %s", err)
+		return
+	}
+	stats, err := parseClusterSlotStats(reply)
+	if err != nil {
+		log.Errorf("Couldn't parse CLUSTER SLOT-STATS reply: %s", err)
+		return
+	}
+
+	var total clusterSlotStats
+	for _, s := range stats {
+		total.keyCount += s.keyCount
+		total.cpuUsec += s.cpuUsec
+		total.networkBytesIn += s.networkBytesIn
+		total.networkBytesOut += s.networkBytesOut
+	}
+	e.registerConstMetricGauge(ch, "cluster_node_slots_key_count", total.keyCount, nodeID)
+	e.registerConstMetric(ch, "cluster_node_slots_cpu_seconds_total", total.cpuUsec/1e6, prometheus.CounterValue, nodeID)
+	e.registerConstMetric(ch, "cluster_node_slots_network_bytes_in_total", total.networkBytesIn, prometheus.CounterValue, nodeID)
+	e.registerConstMetric(ch, "cluster_node_slots_network_bytes_out_total", total.networkBytesOut, prometheus.CounterValue, nodeID)
+
+	topCPU := topClusterSlots(stats, e.options.ClusterSlotStatsLimit, func(s clusterSlotStats) float64 { return s.cpuUsec })
+	if total.cpuUsec > 0 {
+		var topCPUUsec float64
+		for _, s := range topCPU {
+			topCPUUsec += s.cpuUsec
+		}
+		e.registerConstMetricGauge(ch, "cluster_node_top_slots_cpu_ratio", topCPUUsec/total.cpuUsec, nodeID)
+	}
+
+	// a slot can be amongst the top slots for more than one metric but is only exported once
+	topSlots := map[int64]clusterSlotStats{}
+	for _, top := range [][]clusterSlotStats{
+		topClusterSlots(stats, e.options.ClusterSlotStatsLimit, func(s clusterSlotStats) float64 { return s.keyCount }),
+		topCPU,
+		topClusterSlots(stats, e.options.ClusterSlotStatsLimit, func(s clusterSlotStats) float64 { return s.networkBytesIn }),
+		topClusterSlots(stats, e.options.ClusterSlotStatsLimit, func(s clusterSlotStats) float64 { return s.networkBytesOut }),
+	} {
+		for _, s := range top {
+			topSlots[s.slot] = s
+		}
+	}
+
+	for _, s := range topSlots {
+		slot := strconv.FormatInt(s.slot, 10)
+		e.registerConstMetricGauge(ch, "cluster_slot_key_count", s.keyCount, nodeID, slot)
+		e.registerConstMetric(ch, "cluster_slot_cpu_seconds_total", s.cpuUsec/1e6, prometheus.CounterValue, nodeID, slot)
+		e.registerConstMetric(ch, "cluster_slot_network_bytes_in_total", s.networkBytesIn, prometheus.CounterValue, nodeID, slot)
+		e.registerConstMetric(ch, "cluster_slot_network_bytes_out_total", s.networkBytesOut, prometheus.CounterValue, nodeID, slot)
+	}
+}
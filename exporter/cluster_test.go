@@ -0,0 +1,134 @@
+package exporter
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestParseClusterNodeString(t *testing.T) {
+	for _, tst := range []struct {
+		line     string
+		ok       bool
+		id       string
+		address  string
+		masterID string
+		flags    []string
+		link     string
+		epoch    float64
+		slots    int64
+		mig      int64
+		imp      int64
+	}{
+		{
+			line: "e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca 127.0.0.1:30001@31001 myself,master - 0 0 1 connected 0-5460 [93->-292f8b365bb7edb5e285caf0b7e6ddc7265d2f4f]",
+			ok:   true, id: "e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca", address: "127.0.0.1:30001", flags: []string{"myself", "master"},
+			link: "connected", epoch: 1, slots: 5461, mig: 1,
+		},
+		{
+			line: "292f8b365bb7edb5e285caf0b7e6ddc7265d2f4f 127.0.0.1:30003@31003,host-3 master,fail? - 1426238317000 1426238318243 3 disconnected 10923-16383 100 [93-<-e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca] [94-<-e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca]",
+			ok:   true, id: "292f8b365bb7edb5e285caf0b7e6ddc7265d2f4f", address: "127.0.0.1:30003", flags: []string{"master", "pfail"},
+			link: "disconnected", epoch: 3, slots: 5462, imp: 2,
+		},
+		{
+			line: "07c37dfeb235213a872192d90877d0cd55635b91 127.0.0.1:30004 slave e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca 0 1426238317239 4 connected",
+			ok:   true, id: "07c37dfeb235213a872192d90877d0cd55635b91", address: "127.0.0.1:30004", masterID: "e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca", flags: []string{"slave"},
+			link: "connected", epoch: 4,
+		},
+		{line: "07c37dfeb235213a872192d90877d0cd55635b91 127.0.0.1:30004 slave", ok: false},
+		{line: "07c37dfeb235213a872192d90877d0cd55635b91 127.0.0.1:30004 slave - abc 0 4 connected", ok: false},
+		{line: "07c37dfeb235213a872192d90877d0cd55635b91 127.0.0.1:30004 master - 0 0 4 connected 1-abc", ok: false},
+	} {
+		node, ok := parseClusterNodeString(tst.line)
+		if ok != tst.ok {
+			t.Errorf("parseClusterNodeString(%s) - want ok: %t, got: %t", tst.line, tst.ok, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if node.id != tst.id || node.address != tst.address || node.masterID != tst.masterID || node.linkState != tst.link || node.configEpoch != tst.epoch {
+			t.Errorf("parseClusterNodeString(%s) - unexpected node: %#v", tst.line, node)
+		}
+		if node.slots != tst.slots || node.migratingSlots != tst.mig || node.importingSlots != tst.imp {
+			t.Errorf("parseClusterNodeString(%s) - unexpected slots: %d/%d/%d", tst.line, node.slots, node.migratingSlots, node.importingSlots)
+		}
+		if len(node.flags) != len(tst.flags) {
+			t.Errorf("parseClusterNodeString(%s) - want flags: %v, got: %v", tst.line, tst.flags, node.flags)
+		}
+		for _, f := range tst.flags {
+			if !node.flags[f] {
+				t.Errorf("parseClusterNodeString(%s) - missing flag: %s", tst.line, f)
+			}
+		}
+	}
+}
+
+func TestClusterNodesMetrics(t *testing.T) {
+	if os.Getenv("TEST_REDIS_CLUSTER_MASTER_URI") == "" {
+		t.Skipf("TEST_REDIS_CLUSTER_MASTER_URI not set - skipping")
+	}
+
+	addr := os.Getenv("TEST_REDIS_CLUSTER_MASTER_URI")
+	for _, inc := range []bool{true, false} {
+		e, _ := NewRedisExporter(addr, Options{Namespace: "test", Registry: prometheus.NewRegistry(), InclClusterNodesMetrics: inc})
+
+		chM := make(chan prometheus.Metric)
+		go func() {
+			e.Collect(chM)
+			close(chM)
+		}()
+
+		found := false
+		for m := range chM {
+			if strings.Contains(m.Desc().String(), "cluster_node_slots") {
+				found = true
+			}
+		}
+
+		if inc && !found {
+			t.Errorf("cluster_node_slots was *not* found but expected")
+		} else if !inc && found {
+			t.Errorf("cluster_node_slots was *found* but *not* expected")
+		}
+	}
+}
+
+func TestParseClusterSlotStats(t *testing.T) {
+	reply := []interface{}{
+		[]interface{}{int64(100), []interface{}{[]byte("key-count"), int64(5), []byte("cpu-usec"), int64(900), []byte("network-bytes-in"), int64(10), []byte("network-bytes-out"), int64(20)}},
+		[]interface{}{int64(200), []interface{}{[]byte("key-count"), int64(50), []byte("cpu-usec"), int64(100), []byte("network-bytes-in"), int64(30), []byte("network-bytes-out"), int64(0)}},
+		[]interface{}{int64(300), []interface{}{[]byte("key-count"), int64(1)}},
+	}
+
+	stats, err := parseClusterSlotStats(reply)
+	if err != nil {
+		t.Fatalf("parseClusterSlotStats() err: %s", err)
+	}
+	if len(stats) != 3 {
+		t.Fatalf("expected 3 slots, got: %#v", stats)
+	}
+	if stats[0].slot != 100 || stats[0].keyCount != 5 || stats[0].cpuUsec != 900 || stats[0].networkBytesIn != 10 || stats[0].networkBytesOut != 20 {
+		t.Errorf("unexpected stats for slot 100: %#v", stats[0])
+	}
+	if stats[2].slot != 300 || stats[2].keyCount != 1 || stats[2].cpuUsec != 0 {
+		t.Errorf("unexpected stats for slot 300: %#v", stats[2])
+	}
+
+	top := topClusterSlots(stats, 1, func(s clusterSlotStats) float64 { return s.keyCount })
+	if len(top) != 1 || top[0].slot != 200 {
+		t.Errorf("expected slot 200 as top slot by key count, got: %#v", top)
+	}
+
+	// slots without any cpu usage are not considered
+	top = topClusterSlots(stats, 10, func(s clusterSlotStats) float64 { return s.cpuUsec })
+	if len(top) != 2 || top[0].slot != 100 || top[1].slot != 200 {
+		t.Errorf("expected slots 100 and 200 as top slots by cpu usage, got: %#v", top)
+	}
+
+	if _, err := parseClusterSlotStats([]interface{}{[]interface{}{int64(1)}}); err == nil {
+		t.Errorf("expected an error for an invalid reply")
+	}
+}
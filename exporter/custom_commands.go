@@ -0,0 +1,297 @@
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	customCommandReplyScalar = "scalar"
+	customCommandReplyMap    = "map"
+	customCommandReplyPairs  = "pairs"
+
+	// placeholder in the arguments of a custom command that's replaced by the key
+	customCommandKeyPlaceholder = "{{key}}"
+)
+
+// customCommandsAllowlist are the commands that can be used by custom commands, all of them are read-only
+// and don't need to walk the whole keyspace
+var customCommandsAllowlist = map[string]bool{
+	"BITCOUNT":         true,
+	"DBSIZE":           true,
+	"EXISTS":           true,
+	"GET":              true,
+	"GETBIT":           true,
+	"HEXISTS":          true,
+	"HGET":             true,
+	"HGETALL":          true,
+	"HLEN":             true,
+	"HSTRLEN":          true,
+	"LINDEX":           true,
+	"LLEN":             true,
+	"PFCOUNT":          true,
+	"PTTL":             true,
+	"SCARD":            true,
+	"SISMEMBER":        true,
+	"STRLEN":           true,
+	"TTL":              true,
+	"XLEN":             true,
+	"ZCARD":            true,
+	"ZCOUNT":           true,
+	"ZLEXCOUNT":        true,
+	"ZRANGE":           true,
+	"ZRANGEBYSCORE":    true,
+	"ZRANK":            true,
+	"ZREVRANGE":        true,
+	"ZREVRANGEBYSCORE": true,
+	"ZREVRANK":         true,
+	"ZSCORE":           true,
+}
+
+// CustomCommand is a read-only command whose reply is exported as metric, e.g.
+//
+//	{"name": "jobs", "type": "counter", "command": ["HGETALL", "{{key}}"], "key_pattern": "stats:jobs:*", "reply": "map"}
+//
+// exports jobs{field="...",key="stats:jobs:..."} for every field of every hash matching the pattern.
+type CustomCommand struct {
+	// name of the metric
+	Name string `json:"name"`
+	Help string `json:"help"`
+	// gauge (default) or counter
+	Type string `json:"type"`
+	// the command and its arguments, "{{key}}" is replaced by the key
+	Command []string `json:"command"`
+	DB      int      `json:"db"`
+	// the command is run for every key matching the pattern, the key is exported as "key" label
+	KeyPattern string `json:"key_pattern"`
+	// scalar (default), map for flat field/value replies like HGETALL and ZRANGE WITHSCORES
+	// or pairs for replies made of [field, value] arrays
+	Reply string `json:"reply"`
+	// name of the label of the fields of map and pairs replies, defaults to "field"
+	FieldLabel string `json:"field_label"`
+	// constant labels added to all the metrics of the command
+	Labels map[string]string `json:"labels"`
+	// maps non-numeric values, e.g. {"on": 1, "off": 0}, other non-numeric values are skipped
+	Values map[string]float64 `json:"values"`
+}
+
+// LoadCustomCommands reads the list of custom commands from a JSON file
+func LoadCustomCommands(path string) ([]CustomCommand, error) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var res []CustomCommand
+	if err := json.Unmarshal(bytes, &res); err != nil {
+		return nil, fmt.Errorf("custom commands file format error: %s", err)
+	}
+	for _, cc := range res {
+		if err := cc.validate(); err != nil {
+			return nil, err
+		}
+	}
+	log.Debugf("Loaded %d custom commands from %s", len(res), path)
+	return res, nil
+}
+
+func (cc CustomCommand) validate() error {
+	if cc.Name == "" {
+		return fmt.Errorf("custom command without name: %v", cc.Command)
+	}
+	if len(cc.Command) == 0 {
+		return fmt.Errorf("custom command %s: missing command", cc.Name)
+	}
+	if !customCommandsAllowlist[strings.ToUpper(cc.Command[0])] {
+		return fmt.Errorf("custom command %s: %s is not an allowed read-only command", cc.Name, cc.Command[0])
+	}
+	if cc.DB < 0 {
+		return fmt.Errorf("custom command %s: invalid db %d", cc.Name, cc.DB)
+	}
+	switch cc.Type {
+	case "", "gauge", "counter":
+	default:
+		return fmt.Errorf("custom command %s: unsupported type %s", cc.Name, cc.Type)
+	}
+	switch cc.Reply {
+	case "", customCommandReplyScalar, customCommandReplyMap, customCommandReplyPairs:
+	default:
+		return fmt.Errorf("custom command %s: unsupported reply %s", cc.Name, cc.Reply)
+	}
+
+	hasPlaceholder := false
+	for _, arg := range cc.Command[1:] {
+		if strings.Contains(arg, customCommandKeyPlaceholder) {
+			hasPlaceholder = true
+		}
+	}
+	if hasPlaceholder != (cc.KeyPattern != "") {
+		return fmt.Errorf("custom command %s: key_pattern and %s in the command have to be used together", cc.Name, customCommandKeyPlaceholder)
+	}
+	return nil
+}
+
+// isBuiltinMetric returns whether name is the name of one of the metrics of the exporter itself, custom commands
+// with the same name would replace them
+func (e *Exporter) isBuiltinMetric(name string) bool {
+	if _, ok := e.metricDescriptions[name]; ok {
+		return true
+	}
+	for _, n := range e.metricMapGauges {
+		if n == name {
+			return true
+		}
+	}
+	for _, n := range e.metricMapCounters {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (cc CustomCommand) args(key string) []interface{} {
+	res := make([]interface{}, 0, len(cc.Command)-1)
+	for _, arg := range cc.Command[1:] {
+		res = append(res, strings.Replace(arg, customCommandKeyPlaceholder, key, -1))
+	}
+	return res
+}
+
+func (cc CustomCommand) value(v interface{}) (float64, bool) {
+	if i, ok := v.(int64); ok {
+		return float64(i), true
+	}
+	s, err := redis.String(v, nil)
+	if err != nil {
+		return 0, false
+	}
+	if val, err := strconv.ParseFloat(s, 64); err == nil {
+		return val, true
+	}
+	val, ok := cc.Values[s]
+	return val, ok
+}
+
+// metrics turns the reply of the command into metrics, labels holds the constant labels and the key
+func (cc CustomCommand) metrics(reply interface{}, labels map[string]string) ([]scriptMetric, error) {
+	metric := func(val float64, field string) scriptMetric {
+		lbls := make(map[string]string, len(labels)+1)
+		for k, v := range labels {
+			lbls[k] = v
+		}
+		if field != "" {
+			fieldLabel := cc.FieldLabel
+			if fieldLabel == "" {
+				fieldLabel = "field"
+			}
+			lbls[fieldLabel] = field
+		}
+		return scriptMetric{Name: cc.Name, Type: cc.Type, Help: cc.Help, Labels: lbls, Value: val}
+	}
+
+	var res []scriptMetric
+	switch cc.Reply {
+	case "", customCommandReplyScalar:
+		if reply == nil {
+			return nil, nil
+		}
+		if val, ok := cc.value(reply); ok {
+			res = append(res, metric(val, ""))
+		} else {
+			log.Debugf("Custom command %s: skipping non-numeric reply %v", cc.Name, reply)
+		}
+
+	case customCommandReplyMap:
+		values, err := redis.Values(reply, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(values)%2 != 0 {
+			return nil, fmt.Errorf("expected field/value pairs, got %d values", len(values))
+		}
+		for i := 0; i < len(values); i += 2 {
+			field, _ := redis.String(values[i], nil)
+			if val, ok := cc.value(values[i+1]); ok {
+				res = append(res, metric(val, field))
+			} else {
+				log.Debugf("Custom command %s: skipping non-numeric value of %s", cc.Name, field)
+			}
+		}
+
+	case customCommandReplyPairs:
+		values, err := redis.Values(reply, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range values {
+			pair, err := redis.Values(v, nil)
+			if err != nil || len(pair) != 2 {
+				return nil, fmt.Errorf("expected [field, value] pair, got %v", v)
+			}
+			field, _ := redis.String(pair[0], nil)
+			if val, ok := cc.value(pair[1]); ok {
+				res = append(res, metric(val, field))
+			} else {
+				log.Debugf("Custom command %s: skipping non-numeric value of %s", cc.Name, field)
+			}
+		}
+	}
+	return res, nil
+}
+
+func (e *Exporter) extractCustomCommandsMetrics(ch chan<- prometheus.Metric, c redis.Conn) {
+	for _, cc := range e.options.CustomCommands {
+		metrics, err := e.runCustomCommand(c, cc)
+		if err != nil {
+			log.Errorf("Custom command %s err: %s", cc.Name, err)
+			e.registerConstMetricGauge(ch, "custom_command_success", 0, cc.Name)
+			continue
+		}
+		e.registerConstMetricGauge(ch, "custom_command_success", 1, cc.Name)
+		e.registerScriptMetrics(ch, "", metrics)
+	}
+}
+
+func (e *Exporter) runCustomCommand(c redis.Conn, cc CustomCommand) ([]scriptMetric, error) {
+	if _, err := doRedisCmd(c, "SELECT", cc.DB); err != nil {
+		return nil, err
+	}
+
+	if cc.KeyPattern == "" {
+		reply, err := doRedisCmd(c, cc.Command[0], cc.args("")...)
+		if err != nil {
+			return nil, err
+		}
+		return cc.metrics(reply, cc.Labels)
+	}
+
+	keys, err := getKeysFromPatterns(c, []dbKeyPair{{db: strconv.Itoa(cc.DB), key: cc.KeyPattern}}, e.options.CheckKeysBatchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []scriptMetric
+	for _, k := range keys {
+		reply, err := doRedisCmd(c, cc.Command[0], cc.args(k.key)...)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %s", k.key, err)
+		}
+		labels := map[string]string{"key": k.key}
+		for name, v := range cc.Labels {
+			labels[name] = v
+		}
+		metrics, err := cc.metrics(reply, labels)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %s", k.key, err)
+		}
+		res = append(res, metrics...)
+	}
+	return res, nil
+}
@@ -0,0 +1,213 @@
+package exporter
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestCustomCommandValidate(t *testing.T) {
+	for _, tst := range []struct {
+		name    string
+		cc      CustomCommand
+		wantErr bool
+	}{
+		{name: "scalar", cc: CustomCommand{Name: "flag", Command: []string{"GET", "feature:flag"}}},
+		{name: "pattern", cc: CustomCommand{Name: "jobs", Command: []string{"hgetall", "{{key}}"}, KeyPattern: "stats:*", Reply: "map", Type: "counter"}},
+		{name: "no-name", cc: CustomCommand{Command: []string{"GET", "a"}}, wantErr: true},
+		{name: "no-command", cc: CustomCommand{Name: "x"}, wantErr: true},
+		{name: "write-command", cc: CustomCommand{Name: "x", Command: []string{"DEL", "a"}}, wantErr: true},
+		{name: "keys", cc: CustomCommand{Name: "x", Command: []string{"KEYS", "*"}}, wantErr: true},
+		{name: "bad-db", cc: CustomCommand{Name: "x", Command: []string{"GET", "a"}, DB: -1}, wantErr: true},
+		{name: "bad-type", cc: CustomCommand{Name: "x", Command: []string{"GET", "a"}, Type: "summary"}, wantErr: true},
+		{name: "bad-reply", cc: CustomCommand{Name: "x", Command: []string{"GET", "a"}, Reply: "list"}, wantErr: true},
+		{name: "pattern-without-placeholder", cc: CustomCommand{Name: "x", Command: []string{"GET", "a"}, KeyPattern: "a*"}, wantErr: true},
+		{name: "placeholder-without-pattern", cc: CustomCommand{Name: "x", Command: []string{"GET", "{{key}}"}}, wantErr: true},
+	} {
+		t.Run(tst.name, func(t *testing.T) {
+			if err := tst.cc.validate(); (err != nil) != tst.wantErr {
+				t.Errorf("validate() want error: %t, got: %v", tst.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCustomCommandBuiltinMetricName(t *testing.T) {
+	for _, name := range []string{"up", "db_keys", "memory_used_bytes", "custom_command_success"} {
+		cc := CustomCommand{Name: name, Command: []string{"GET", "a"}}
+		if _, err := NewRedisExporter("", Options{CustomCommands: []CustomCommand{cc}}); err == nil {
+			t.Errorf("expected an error for the custom command %s", name)
+		}
+	}
+
+	cc := CustomCommand{Name: "feature_flag", Command: []string{"GET", "a"}}
+	if _, err := NewRedisExporter("", Options{CustomCommands: []CustomCommand{cc}}); err != nil {
+		t.Errorf("expected no error, got: %s", err)
+	}
+}
+
+func TestCustomCommandMetrics(t *testing.T) {
+	e, _ := NewRedisExporter("", Options{Namespace: "test"})
+
+	for _, tst := range []struct {
+		name  string
+		cc    CustomCommand
+		reply interface{}
+		want  map[string]float64
+	}{
+		{
+			name:  "scalar",
+			cc:    CustomCommand{Name: "leaderboard_score", Labels: map[string]string{"member": "x"}},
+			reply: []byte("12.5"),
+			want:  map[string]float64{`leaderboard_score{member="x"}`: 12.5},
+		},
+		{
+			name:  "scalar-int",
+			cc:    CustomCommand{Name: "members"},
+			reply: int64(3),
+			want:  map[string]float64{`members`: 3},
+		},
+		{
+			name:  "scalar-mapped",
+			cc:    CustomCommand{Name: "feature_flag", Values: map[string]float64{"on": 1, "off": 0}},
+			reply: []byte("on"),
+			want:  map[string]float64{`feature_flag`: 1},
+		},
+		{
+			name:  "scalar-missing",
+			cc:    CustomCommand{Name: "feature_flag"},
+			reply: nil,
+			want:  map[string]float64{},
+		},
+		{
+			name:  "map",
+			cc:    CustomCommand{Name: "jobs", Reply: "map", FieldLabel: "state"},
+			reply: []interface{}{[]byte("done"), []byte("10"), []byte("failed"), []byte("2"), []byte("last"), []byte("abc")},
+			want:  map[string]float64{`jobs{key="stats:jobs",state="done"}`: 10, `jobs{key="stats:jobs",state="failed"}`: 2},
+		},
+		{
+			name: "pairs",
+			cc:   CustomCommand{Name: "scores", Reply: "pairs"},
+			reply: []interface{}{
+				[]interface{}{[]byte("alice"), []byte("3")},
+				[]interface{}{[]byte("bob"), []byte("1")},
+			},
+			want: map[string]float64{`scores{field="alice",key="stats:jobs"}`: 3, `scores{field="bob",key="stats:jobs"}`: 1},
+		},
+	} {
+		t.Run(tst.name, func(t *testing.T) {
+			labels := tst.cc.Labels
+			if tst.cc.Reply != "" {
+				labels = map[string]string{"key": "stats:jobs"}
+			}
+			metrics, err := tst.cc.metrics(tst.reply, labels)
+			if err != nil {
+				t.Fatalf("metrics() err: %s", err)
+			}
+
+			got := collectGauges(func(ch chan<- prometheus.Metric) {
+				e.registerScriptMetrics(ch, "", metrics)
+			})
+			if len(got) != len(tst.want) {
+				t.Errorf("want %d metrics, got: %#v", len(tst.want), got)
+			}
+			for k, v := range tst.want {
+				if val, ok := got[k]; !ok || val != v {
+					t.Errorf("metric %s: want %f, got: %#v", k, v, got)
+				}
+			}
+		})
+	}
+
+	for _, reply := range []interface{}{[]interface{}{[]byte("field")}, int64(1)} {
+		if _, err := (CustomCommand{Name: "x", Reply: "map"}).metrics(reply, nil); err == nil {
+			t.Errorf("expected error for map reply: %#v", reply)
+		}
+	}
+	if _, err := (CustomCommand{Name: "x", Reply: "pairs"}).metrics([]interface{}{[]byte("a"), []byte("1")}, nil); err == nil {
+		t.Errorf("expected error for flat pairs reply")
+	}
+}
+
+func TestLoadCustomCommands(t *testing.T) {
+	f, err := ioutil.TempFile("", "custom-commands")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	f.WriteString(`[{"name": "jobs", "command": ["HGETALL", "{{key}}"], "db": 1, "key_pattern": "stats:*", "reply": "map", "type": "counter"}]`)
+	f.Close()
+
+	commands, err := LoadCustomCommands(f.Name())
+	if err != nil {
+		t.Fatalf("LoadCustomCommands() err: %s", err)
+	}
+	if len(commands) != 1 || commands[0].Name != "jobs" || commands[0].DB != 1 || commands[0].KeyPattern != "stats:*" || commands[0].Reply != "map" {
+		t.Errorf("unexpected commands: %#v", commands)
+	}
+
+	if err := ioutil.WriteFile(f.Name(), []byte(`[{"name": "x", "command": ["FLUSHALL"]}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCustomCommands(f.Name()); err == nil {
+		t.Errorf("expected error for a write command")
+	}
+
+	if _, err := LoadCustomCommands(filepath.Join(os.TempDir(), "does-not-exist.json")); err == nil {
+		t.Errorf("expected error for a missing file")
+	}
+}
+
+func TestCustomCommandsMetrics(t *testing.T) {
+	if os.Getenv("TEST_REDIS_URI") == "" {
+		t.Skipf("TEST_REDIS_URI not set - skipping")
+	}
+	addr := os.Getenv("TEST_REDIS_URI")
+
+	c, err := redis.DialURL(addr)
+	if err != nil {
+		t.Fatalf("Couldn't connect to %#v: %#v", addr, err)
+	}
+	defer c.Close()
+
+	if _, err := doRedisCmd(c, "SELECT", dbNumStr); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"custom_stats:api", "custom_stats:web"} {
+		if _, err := doRedisCmd(c, "HSET", key, "calls", 10, "errors", 1); err != nil {
+			t.Fatal(err)
+		}
+		defer doRedisCmd(c, "DEL", key)
+	}
+
+	e, _ := NewRedisExporter(addr, Options{
+		Namespace:          "test",
+		Registry:           prometheus.NewRegistry(),
+		CheckKeysBatchSize: 1000,
+		CustomCommands: []CustomCommand{
+			{Name: "custom_stats", Type: "counter", Command: []string{"HGETALL", "{{key}}"}, DB: 11, KeyPattern: "custom_stats:*", Reply: "map"},
+			{Name: "custom_stats_calls", Command: []string{"HGET", "custom_stats:api", "calls"}, DB: 11},
+		},
+	})
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+
+	body := downloadURL(t, ts.URL+"/metrics")
+	for _, want := range []string{
+		`test_custom_stats{field="calls",key="custom_stats:api"} 10`,
+		`test_custom_stats{field="errors",key="custom_stats:web"} 1`,
+		`test_custom_stats_calls 10`,
+		`test_custom_command_success{name="custom_stats"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("want metrics to include %s, have:\n%s", want, body)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package exporter
 import (
 	"fmt"
 	"net/http"
+	"regexp"
 	"runtime"
 	"strconv"
 	"strings"
@@ -42,41 +43,106 @@ type Exporter struct {
 	mux *http.ServeMux
 
 	buildInfo BuildInfo
+
+	// used to estimate the replication lag of connected replicas in seconds, shared with the exporters of /scrape
+	replOffsets *replOffsetSamples
+
+	// state of the sampling collectors and the ACL LOG, kept across scrapes
+	collectorState
+
+	// the collector state per target of the exporters of /scrape
+	targetStates *targetStates
+
+	keyEvents          *keyEventsSubscriber
+	keyEventsKeyGroups []*regexp.Regexp
+
+	keyGroupMatcher *keyGroupMatcher
+
+	keyLabelRegexes []*keyLabelRegex
+	keyLabelNames   []string
+	keyLabelDescs   map[string]*prometheus.Desc
+
+	luaScripts []*luaScriptRunner
+
+	// whether CollectFunctionLibrary was loaded already
+	collectFunctionLoaded bool
 }
 
 type Options struct {
-	User                  string
-	Password              string
-	Namespace             string
-	PasswordMap           map[string]string
-	ConfigCommandName     string
-	CheckKeys             string
-	CheckSingleKeys       string
-	CheckStreams          string
-	CheckSingleStreams    string
-	CheckKeysBatchSize    int64
-	CheckKeyGroups        string
-	MaxDistinctKeyGroups  int64
-	CountKeys             string
-	LuaScript             []byte
-	ClientCertFile        string
-	ClientKeyFile         string
-	CaCertFile            string
-	InclConfigMetrics     bool
-	RedactConfigMetrics   bool
-	InclSystemMetrics     bool
-	SkipTLSVerification   bool
-	SetClientName         bool
-	IsTile38              bool
-	IsCluster             bool
-	ExportClientList      bool
-	ExportClientsInclPort bool
-	ConnectionTimeouts    time.Duration
-	MetricsPath           string
-	RedisMetricsOnly      bool
-	PingOnConnect         bool
-	Registry              *prometheus.Registry
-	BuildInfo             BuildInfo
+	User                       string
+	Password                   string
+	Namespace                  string
+	PasswordMap                map[string]string
+	ConfigCommandName          string
+	CheckKeys                  string
+	CheckSingleKeys            string
+	CheckKeyFields             string
+	CheckZSetQueues            string
+	ZSetQueueWindows           string
+	KeyLabelRegexes            string
+	KeyLabelRegexesDropKey     bool
+	CheckKeysDetails           bool
+	KeyMemoryUsageSamples      int64
+	CheckStreams               string
+	CheckSingleStreams         string
+	CheckPubSubChannels        string
+	CheckSinglePubSubChannels  string
+	CheckKeysBatchSize         int64
+	CheckKeyGroups             string
+	MaxDistinctKeyGroups       int64
+	KeyGroupsBreakdown         bool
+	KeyGroupGlobs              string
+	KeyGroupPrefixDelimiter    string
+	KeyGroupPrefixDepth        int64
+	KeyCountEstimateSamples    int64
+	CheckBigKeys               bool
+	BigKeysTopN                int64
+	CheckHotKeys               bool
+	HotKeysTopN                int64
+	CheckTTLDistribution       bool
+	TTLDistributionByKeyGroup  bool
+	CheckKeyIdleTime           bool
+	ExportKeyEvents            bool
+	KeyEventsKeyGroups         string
+	SampleKeysBudget           int64
+	SampleKeysTimeout          time.Duration
+	CountKeys                  string
+	LuaScript                  []byte
+	LuaScripts                 []LuaScript
+	CollectFunction            string
+	CollectFunctionLibrary     []byte
+	CollectFunctionPrefix      string
+	CollectFunctionDB          int64
+	CustomCommands             []CustomCommand
+	ClientCertFile             string
+	ClientKeyFile              string
+	CaCertFile                 string
+	InclConfigMetrics          bool
+	InclClusterNodesMetrics    bool
+	ExportClusterSlotStats     bool
+	ClusterSlotStatsLimit      int64
+	RedactConfigMetrics        bool
+	InclSystemMetrics          bool
+	InclMemoryStatsMetrics     bool
+	InclFunctionsMetrics       bool
+	SkipTLSVerification        bool
+	SetClientName              bool
+	IsTile38                   bool
+	IsCluster                  bool
+	ExportClientList           bool
+	ExportClientsInclPort      bool
+	ExportClientListAggregated bool
+	ExportACLLog               bool
+	ACLLogCount                int64
+	ACLLogMaxObjects           int64
+	ACLLogMaxUsernames         int64
+	ExportACLUsers             bool
+	ConnectionTimeouts         time.Duration
+	MetricsPath                string
+	RedisMetricsOnly           bool
+	PingOnConnect              bool
+	Registry                   *prometheus.Registry
+	BuildInfo                  BuildInfo
 }
 
 // NewRedisExporter returns a new exporter of Redis metrics.
@@ -90,6 +156,9 @@ func NewRedisExporter(redisURI string, opts Options) (*Exporter, error) {
 
 		buildInfo: opts.BuildInfo,
 
+		replOffsets:  &replOffsetSamples{samples: map[string]*replOffsetSample{}},
+		targetStates: &targetStates{states: map[string]*targetState{}},
+
 		totalScrapes: prometheus.NewCounter(prometheus.CounterOpts{
 			Namespace: opts.Namespace,
 			Name:      "exporter_scrapes_total",
@@ -293,6 +362,84 @@ func NewRedisExporter(redisURI string, opts Options) (*Exporter, error) {
 		e.options.ConfigCommandName = "CONFIG"
 	}
 
+	if e.options.ClusterSlotStatsLimit <= 0 {
+		e.options.ClusterSlotStatsLimit = 10
+	}
+
+	if e.options.ACLLogCount <= 0 {
+		e.options.ACLLogCount = 128
+	}
+
+	if e.options.ACLLogMaxObjects <= 0 {
+		e.options.ACLLogMaxObjects = 100
+	}
+
+	if e.options.ACLLogMaxUsernames <= 0 {
+		e.options.ACLLogMaxUsernames = 100
+	}
+
+	if e.options.BigKeysTopN <= 0 {
+		e.options.BigKeysTopN = 10
+	}
+
+	if e.options.HotKeysTopN <= 0 {
+		e.options.HotKeysTopN = 10
+	}
+
+	if e.options.MaxDistinctKeyGroups <= 0 {
+		e.options.MaxDistinctKeyGroups = 100
+	}
+
+	if e.options.KeyMemoryUsageSamples < 0 {
+		// 0 is passed on, MEMORY USAGE with SAMPLES 0 walks all nested values
+		e.options.KeyMemoryUsageSamples = 5
+	}
+
+	if e.options.CollectFunctionPrefix == "" {
+		// like the scripts of the script directory the metrics of the function are prefixed with its name
+		e.options.CollectFunctionPrefix = e.options.CollectFunction
+	}
+
+	if e.options.SampleKeysBudget <= 0 {
+		e.options.SampleKeysBudget = 1000
+	}
+
+	if e.options.SampleKeysTimeout <= 0 {
+		e.options.SampleKeysTimeout = time.Second
+	}
+
+	if groups, err := parseKeyEventsKeyGroups(opts.KeyEventsKeyGroups); err != nil {
+		return nil, fmt.Errorf("couldn't parse key-events-key-groups: %s", err)
+	} else {
+		e.keyEventsKeyGroups = groups
+	}
+
+	if m, err := parseKeyGroupMatcher(opts.KeyGroupGlobs, opts.KeyGroupPrefixDelimiter, opts.KeyGroupPrefixDepth); err != nil {
+		return nil, fmt.Errorf("couldn't parse key-group-globs: %s", err)
+	} else if m != nil && strings.TrimSpace(opts.CheckKeyGroups) != "" {
+		return nil, fmt.Errorf("check-key-groups can't be used together with key-group-globs or key-group-prefix-delimiter")
+	} else {
+		e.keyGroupMatcher = m
+	}
+
+	if regexes, err := parseKeyLabelRegexes(opts.KeyLabelRegexes); err != nil {
+		return nil, fmt.Errorf("couldn't parse key-label-regexes: %s", err)
+	} else if len(regexes) > 0 && opts.KeyCountEstimateSamples > 0 && strings.TrimSpace(opts.CountKeys) != "" {
+		// the estimates are per count-keys pattern, there are no keys to match the regexes against
+		return nil, fmt.Errorf("key-label-regexes can't be used together with count-keys and key-count-estimate-samples")
+	} else {
+		e.keyLabelRegexes = regexes
+		e.keyLabelNames = keyLabelNames(regexes)
+	}
+
+	e.luaScripts = newLuaScriptRunners(opts.LuaScripts)
+
+	for _, cc := range opts.CustomCommands {
+		if err := cc.validate(); err != nil {
+			return nil, err
+		}
+	}
+
 	if keys, err := parseKeyArg(opts.CheckKeys); err != nil {
 		return nil, fmt.Errorf("couldn't parse check-keys: %s", err)
 	} else {
@@ -305,6 +452,22 @@ func NewRedisExporter(redisURI string, opts Options) (*Exporter, error) {
 		log.Debugf("singleKeys: %#v", singleKeys)
 	}
 
+	if keyFields, err := parseKeyFieldsArg(opts.CheckKeyFields); err != nil {
+		return nil, fmt.Errorf("couldn't parse check-key-fields: %s", err)
+	} else {
+		log.Debugf("keyFields: %#v", keyFields)
+	}
+
+	if queues, err := parseZSetQueuesArg(opts.CheckZSetQueues); err != nil {
+		return nil, fmt.Errorf("couldn't parse check-zset-queues: %s", err)
+	} else {
+		log.Debugf("zsetQueues: %#v", queues)
+	}
+
+	if _, err := parseZSetQueueWindows(opts.ZSetQueueWindows); err != nil {
+		return nil, fmt.Errorf("couldn't parse zset-queue-windows: %s", err)
+	}
+
 	if streams, err := parseKeyArg(opts.CheckStreams); err != nil {
 		return nil, fmt.Errorf("couldn't parse check-streams: %s", err)
 	} else {
@@ -317,6 +480,18 @@ func NewRedisExporter(redisURI string, opts Options) (*Exporter, error) {
 		log.Debugf("singleStreams: %#v", singleStreams)
 	}
 
+	if channels, err := parseChannelArg(opts.CheckPubSubChannels); err != nil {
+		return nil, fmt.Errorf("couldn't parse check-pubsub-channels: %s", err)
+	} else {
+		log.Debugf("pubsubChannels: %#v", channels)
+	}
+
+	if singleChannels, err := parseChannelArg(opts.CheckSinglePubSubChannels); err != nil {
+		return nil, fmt.Errorf("couldn't parse check-single-pubsub-channels: %s", err)
+	} else {
+		log.Debugf("singlePubsubChannels: %#v", singleChannels)
+	}
+
 	if countKeys, err := parseKeyArg(opts.CountKeys); err != nil {
 		return nil, fmt.Errorf("couldn't parse count-keys: %s", err)
 	} else {
@@ -328,16 +503,45 @@ func NewRedisExporter(redisURI string, opts Options) (*Exporter, error) {
 	}
 
 	e.metricDescriptions = map[string]*prometheus.Desc{}
+	e.keyLabelDescs = map[string]*prometheus.Desc{}
 
 	connectedClientsLabels := []string{"name", "created_at", "idle_since", "flags", "db", "omem", "cmd", "host"}
 	if e.options.ExportClientsInclPort {
 		connectedClientsLabels = append(connectedClientsLabels, "port")
 	}
+	clientGroupLabels := []string{"name", "user", "lib_name", "lib_ver", "flags", "host"}
 
 	for k, desc := range map[string]struct {
 		txt  string
 		lbls []string
 	}{
+		"acl_denials_total":                            {txt: `Total number of denied commands and authentications from the ACL LOG`, lbls: []string{"reason", "context", "username", "object"}},
+		"acl_user_allcommands":                         {txt: `Whether the ACL user is allowed to run all commands`, lbls: []string{"username"}},
+		"acl_user_allkeys":                             {txt: `Whether the ACL user is allowed to access all keys`, lbls: []string{"username"}},
+		"acl_user_enabled":                             {txt: `Whether the ACL user is enabled`, lbls: []string{"username"}},
+		"acl_user_nopass":                              {txt: `Whether the ACL user can authenticate with any password`, lbls: []string{"username"}},
+		"acl_user_passwords":                           {txt: `Number of passwords of the ACL user`, lbls: []string{"username"}},
+		"acl_user_selectors":                           {txt: `Number of selectors of the ACL user`, lbls: []string{"username"}},
+		"big_key_memory_usage_bytes":                   {txt: `Memory usage of one of the biggest sampled keys in bytes`, lbls: []string{"db", "type", "key"}},
+		"big_key_size":                                 {txt: `The length or size of one of the biggest sampled keys`, lbls: []string{"db", "type", "key"}},
+		"big_keys_sampled_memory_usage_bytes":          {txt: `Memory usage of the sampled keys in bytes`, lbls: []string{"db", "type"}},
+		"cluster_node_config_epoch":                    {txt: "Config epoch of the cluster node", lbls: []string{"node_id", "address", "master_id"}},
+		"cluster_node_flag":                            {txt: "Whether the cluster node has the flag set", lbls: []string{"node_id", "address", "master_id", "flag"}},
+		"cluster_node_importing_slots":                 {txt: "Number of slots the cluster node is importing", lbls: []string{"node_id", "address", "master_id"}},
+		"cluster_node_link_connected":                  {txt: "Whether the link to the cluster node is connected", lbls: []string{"node_id", "address", "master_id"}},
+		"cluster_node_migrating_slots":                 {txt: "Number of slots the cluster node is migrating", lbls: []string{"node_id", "address", "master_id"}},
+		"cluster_node_ping_sent_age_seconds":           {txt: "Seconds since the currently pending ping was sent to the cluster node, zero if there is none", lbls: []string{"node_id", "address", "master_id"}},
+		"cluster_node_pong_received_age_seconds":       {txt: "Seconds since the last pong was received from the cluster node", lbls: []string{"node_id", "address", "master_id"}},
+		"cluster_node_slots":                           {txt: "Number of slots owned by the cluster node", lbls: []string{"node_id", "address", "master_id"}},
+		"cluster_node_slots_cpu_seconds_total":         {txt: "Total CPU time spent on all slots of the cluster node in seconds", lbls: []string{"node_id"}},
+		"cluster_node_slots_key_count":                 {txt: "Number of keys in all slots of the cluster node", lbls: []string{"node_id"}},
+		"cluster_node_slots_network_bytes_in_total":    {txt: "Total network input of all slots of the cluster node in bytes", lbls: []string{"node_id"}},
+		"cluster_node_slots_network_bytes_out_total":   {txt: "Total network output of all slots of the cluster node in bytes", lbls: []string{"node_id"}},
+		"cluster_node_top_slots_cpu_ratio":             {txt: "Share of the CPU time of the cluster node spent on its top slots by CPU usage", lbls: []string{"node_id"}},
+		"cluster_slot_cpu_seconds_total":               {txt: "Total CPU time spent on the cluster slot in seconds", lbls: []string{"node_id", "slot"}},
+		"cluster_slot_key_count":                       {txt: "Number of keys in the cluster slot", lbls: []string{"node_id", "slot"}},
+		"cluster_slot_network_bytes_in_total":          {txt: "Total network input of the cluster slot in bytes", lbls: []string{"node_id", "slot"}},
+		"cluster_slot_network_bytes_out_total":         {txt: "Total network output of the cluster slot in bytes", lbls: []string{"node_id", "slot"}},
 		"commands_duration_seconds_total":              {txt: `Total amount of time in seconds spent per command`, lbls: []string{"cmd"}},
 		"commands_failed_calls_total":                  {txt: `Total number of errors prior command execution per command`, lbls: []string{"cmd"}},
 		"commands_rejected_calls_total":                {txt: `Total number of errors within command execution per command`, lbls: []string{"cmd"}},
@@ -345,20 +549,63 @@ func NewRedisExporter(redisURI string, opts Options) (*Exporter, error) {
 		"config_key_value":                             {txt: `Config key and value`, lbls: []string{"key", "value"}},
 		"config_value":                                 {txt: `Config key and value as metric`, lbls: []string{"key"}},
 		"connected_clients_details":                    {txt: "Details about connected clients", lbls: connectedClientsLabels},
+		"connected_clients_group_connections":          {txt: "Number of connections per client group", lbls: clientGroupLabels},
+		"connected_clients_group_idle_seconds":         {txt: "Idle time of the connections per client group", lbls: clientGroupLabels},
+		"connected_clients_group_omem_bytes":           {txt: "Total output buffer memory of the connections per client group", lbls: clientGroupLabels},
+		"connected_clients_group_omem_max_bytes":       {txt: "Largest output buffer memory of a connection per client group", lbls: clientGroupLabels},
+		"connected_clients_group_qbuf_bytes":           {txt: "Total query buffer length of the connections per client group", lbls: clientGroupLabels},
+		"connected_clients_group_qbuf_max_bytes":       {txt: "Largest query buffer length of a connection per client group", lbls: clientGroupLabels},
+		"connected_clients_group_tot_mem_bytes":        {txt: "Total memory consumed by the connections per client group", lbls: clientGroupLabels},
+		"connected_clients_group_tot_mem_max_bytes":    {txt: "Largest memory consumed by a connection per client group", lbls: clientGroupLabels},
 		"connected_slave_lag_seconds":                  {txt: "Lag of connected slave", lbls: []string{"slave_ip", "slave_port", "slave_state"}},
 		"connected_slave_offset_bytes":                 {txt: "Offset of connected slave", lbls: []string{"slave_ip", "slave_port", "slave_state"}},
+		"connected_replica_lag_bytes":                  {txt: "Number of bytes the connected replica is behind the master replication offset", lbls: []string{"slave_ip", "slave_port", "slave_state"}},
+		"connected_replica_lag_estimated_seconds":      {txt: "Estimated lag of the connected replica in seconds, based on the recent growth rate of the master replication offset", lbls: []string{"slave_ip", "slave_port", "slave_state"}},
+		"custom_command_success":                       {txt: "Whether the custom command ran successfully", lbls: []string{"name"}},
 		"db_avg_ttl_seconds":                           {txt: "Avg TTL in seconds", lbls: []string{"db"}},
 		"db_keys":                                      {txt: "Total number of keys by DB", lbls: []string{"db"}},
 		"db_keys_expiring":                             {txt: "Total number of expiring keys by DB", lbls: []string{"db"}},
 		"errors_total":                                 {txt: `Total number of errors per error type`, lbls: []string{"err"}},
 		"exporter_last_scrape_error":                   {txt: "The last scrape error status.", lbls: []string{"err"}},
+		"function_collect_duration_seconds":            {txt: `Run time of the collect function in seconds`, lbls: []string{"function"}},
+		"function_collect_success":                     {txt: `Whether the collect function ran successfully`, lbls: []string{"function"}},
+		"function_engine_functions":                    {txt: `Number of functions per engine`, lbls: []string{"engine"}},
+		"function_engine_libraries":                    {txt: `Number of libraries per engine`, lbls: []string{"engine"}},
+		"function_info":                                {txt: `Information about the loaded functions`, lbls: []string{"library", "function", "flags"}},
+		"function_library_functions":                   {txt: `Number of functions of the library`, lbls: []string{"library", "engine"}},
+		"function_library_info":                        {txt: `Information about the loaded function libraries`, lbls: []string{"library", "engine"}},
+		"function_running":                             {txt: `Whether a function or script is running right now`},
+		"function_running_duration_seconds":            {txt: `Run time of the currently running function or script`, lbls: []string{"name"}},
+		"hot_key_frequency":                            {txt: `Logarithmic access frequency counter of one of the hottest sampled keys`, lbls: []string{"db", "key"}},
+		"hot_keys_supported":                           {txt: `Whether hot key detection is supported, it requires an LFU maxmemory-policy`},
 		"instance_info":                                {txt: "Information about the Redis instance", lbls: []string{"role", "redis_version", "redis_build_id", "redis_mode", "os", "maxmemory_policy", "tcp_port", "run_id", "process_id"}},
+		"key_encoding_info":                            {txt: `Internal encoding of "key"`, lbls: []string{"db", "key", "encoding"}},
+		"key_events_subscribed":                        {txt: `Whether the key events subscriber is connected`},
+		"key_events_total":                             {txt: `Total number of key events received`, lbls: []string{"db", "event", "key_group"}},
+		"key_field_value":                              {txt: `The value of a field of the hash "key"`, lbls: []string{"db", "key", "field"}},
+		"key_field_value_as_string":                    {txt: `The value of a field of the hash "key" as a string`, lbls: []string{"db", "key", "field", "val"}},
 		"key_group_count":                              {txt: `Count of keys in key group`, lbls: []string{"db", "key_group"}},
+		"key_group_encoding_count":                     {txt: `Count of keys in key group by object encoding`, lbls: []string{"db", "key_group", "encoding"}},
+		"key_group_count_estimate":                     {txt: `Estimated count of keys in key group`, lbls: []string{"db", "key_group"}},
+		"key_group_count_estimate_stddev":              {txt: `Standard deviation of the estimated count of keys in key group`, lbls: []string{"db", "key_group"}},
+		"key_group_estimate_samples":                   {txt: `Number of random keys sampled to estimate the key groups`, lbls: []string{"db"}},
 		"key_group_memory_usage_bytes":                 {txt: `Total memory usage of key group in bytes`, lbls: []string{"db", "key_group"}},
+		"key_group_memory_usage_bytes_estimate":        {txt: `Estimated total memory usage of key group in bytes`, lbls: []string{"db", "key_group"}},
+		"key_group_type_count":                         {txt: `Count of keys in key group by type`, lbls: []string{"db", "key_group", "type"}},
+		"key_group_type_memory_usage_bytes":            {txt: `Total memory usage of key group by type in bytes`, lbls: []string{"db", "key_group", "type"}},
+		"key_group_with_ttl_count":                     {txt: `Count of keys with TTL in key group`, lbls: []string{"db", "key_group"}},
+		"key_group_without_ttl_count":                  {txt: `Count of keys without TTL in key group`, lbls: []string{"db", "key_group"}},
+		"key_idle_seconds":                             {txt: `Time since "key" was last accessed in seconds`, lbls: []string{"db", "key"}},
+		"key_memory_usage_bytes":                       {txt: `Memory usage of "key" in bytes`, lbls: []string{"db", "key"}},
 		"key_size":                                     {txt: `The length or size of "key"`, lbls: []string{"db", "key"}},
+		"key_ttl_seconds":                              {txt: `TTL of "key" in seconds, -1 if it has no TTL`, lbls: []string{"db", "key"}},
 		"key_value":                                    {txt: `The value of "key"`, lbls: []string{"db", "key"}},
 		"key_value_as_string":                          {txt: `The value of "key" as a string`, lbls: []string{"db", "key", "val"}},
 		"keys_count":                                   {txt: `Count of keys`, lbls: []string{"db", "key"}},
+		"keys_count_by_type":                           {txt: `Count of keys by type`, lbls: []string{"db", "key", "type"}},
+		"keys_count_estimate":                          {txt: `Estimated count of keys`, lbls: []string{"db", "key"}},
+		"keys_count_estimate_samples":                  {txt: `Number of random keys sampled to estimate key counts`, lbls: []string{"db"}},
+		"keys_count_estimate_stddev":                   {txt: `Standard deviation of the estimated count of keys`, lbls: []string{"db", "key"}},
 		"last_key_groups_scrape_duration_milliseconds": {txt: `Duration of the last key group metrics scrape in milliseconds`},
 		"last_slow_execution_duration_seconds":         {txt: `The amount of time needed for last slow execution, in seconds`},
 		"latency_spike_duration_seconds":               {txt: `Length of the last latency spike in seconds`, lbls: []string{"event_name"}},
@@ -366,7 +613,17 @@ func NewRedisExporter(redisURI string, opts Options) (*Exporter, error) {
 		"master_last_io_seconds_ago":                   {txt: "Master last io seconds ago", lbls: []string{"master_host", "master_port"}},
 		"master_link_up":                               {txt: "Master link status on Redis slave", lbls: []string{"master_host", "master_port"}},
 		"master_sync_in_progress":                      {txt: "Master sync in progress", lbls: []string{"master_host", "master_port"}},
+		"memory_stats_db_expires_overhead_bytes":       {txt: "Memory overhead of the expires hashtable of the db in bytes", lbls: []string{"db"}},
+		"memory_stats_db_main_overhead_bytes":          {txt: "Memory overhead of the main hashtable of the db in bytes", lbls: []string{"db"}},
 		"number_of_distinct_key_groups":                {txt: `Number of distinct key groups`, lbls: []string{"db"}},
+		"pubsub_channel_subscribers":                   {txt: `Number of subscribers of the pub/sub channel`, lbls: []string{"channel"}},
+		"pubsub_numpat":                                {txt: `Number of unique patterns subscribed to`},
+		"pubsub_shard_channel_subscribers":             {txt: `Number of subscribers of the sharded pub/sub channel`, lbls: []string{"channel"}},
+		"sampled_keys_idle_seconds":                    {txt: `Idle time of the sampled keys in seconds`, lbls: []string{"db", "key_group"}},
+		"sampled_keys_ttl_seconds":                     {txt: `TTL of the sampled keys with a TTL in seconds`, lbls: []string{"db", "key_group"}},
+		"sampled_keys_without_ttl_total":               {txt: `Number of sampled keys without TTL`, lbls: []string{"db", "key_group"}},
+		"script_duration_seconds":                      {txt: "Run time of the collect script in seconds", lbls: []string{"script"}},
+		"script_success":                               {txt: "Whether the collect script ran successfully", lbls: []string{"script"}},
 		"script_values":                                {txt: "Values returned by the collect script", lbls: []string{"key"}},
 		"sentinel_master_ok_sentinels":                 {txt: "The number of okay sentinels monitoring this master", lbls: []string{"master_name", "master_address"}},
 		"sentinel_master_ok_slaves":                    {txt: "The number of okay slaves of the master", lbls: []string{"master_name", "master_address"}},
@@ -393,9 +650,21 @@ func NewRedisExporter(redisURI string, opts Options) (*Exporter, error) {
 		"stream_length":                                {txt: `The number of elements of the stream`, lbls: []string{"db", "stream"}},
 		"stream_radix_tree_keys":                       {txt: `Radix tree keys count"`, lbls: []string{"db", "stream"}},
 		"stream_radix_tree_nodes":                      {txt: `Radix tree nodes count`, lbls: []string{"db", "stream"}},
+		"zset_queue_oldest_overdue_age_seconds":        {txt: `Age of the oldest overdue member of the sorted set queue in seconds`, lbls: []string{"db", "key"}},
+		"zset_queue_overdue_count":                     {txt: `Count of members of the sorted set queue with a score before now`, lbls: []string{"db", "key"}},
+		"zset_queue_window_count":                      {txt: `Count of members of the sorted set queue with a score in the window relative to now`, lbls: []string{"db", "key", "window"}},
 		"up":                                           {txt: "Information about the Redis instance"},
 	} {
 		e.metricDescriptions[k] = newMetricDescr(opts.Namespace, k, desc.txt, desc.lbls)
+		if keyLabelMetrics[k] && len(e.keyLabelRegexes) > 0 {
+			e.keyLabelDescs[k] = newMetricDescr(opts.Namespace, k+"_by_labels", desc.txt+" by the labels of key-label-regexes", keyLabelsMetricLabels(desc.lbls, e.keyLabelNames, opts.KeyLabelRegexesDropKey))
+		}
+	}
+
+	for _, cc := range opts.CustomCommands {
+		if e.isBuiltinMetric(sanitizeMetricName(cc.Name)) {
+			return nil, fmt.Errorf("custom command %s: the name is used by a metric of the exporter", cc.Name)
+		}
 	}
 
 	if e.options.MetricsPath == "" {
@@ -434,6 +703,10 @@ func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
 		ch <- desc
 	}
 
+	for _, desc := range e.keyLabelDescs {
+		ch <- desc
+	}
+
 	for _, v := range e.metricMapGauges {
 		ch <- newMetricDescr(e.options.Namespace, v, v+" metric", nil)
 	}
@@ -475,6 +748,24 @@ func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 	ch <- e.targetScrapeRequestErrors
 }
 
+func getConfigValue(config []string, key string) string {
+	for pos := 0; pos+1 < len(config); pos += 2 {
+		if config[pos] == key {
+			return config[pos+1]
+		}
+	}
+	return ""
+}
+
+func getInfoValue(info string, key string) string {
+	for _, line := range strings.Split(info, "\n") {
+		if strings.HasPrefix(line, key+":") {
+			return strings.TrimSpace(strings.TrimPrefix(line, key+":"))
+		}
+	}
+	return ""
+}
+
 func (e *Exporter) extractConfigMetrics(ch chan<- prometheus.Metric, config []string) (dbCount int, err error) {
 	if len(config)%2 != 0 {
 		return 0, fmt.Errorf("invalid config: %#v", config)
@@ -531,7 +822,12 @@ func (e *Exporter) scrapeRedisHost(ch chan<- prometheus.Metric) error {
 		log.Debugf("connectToRedis( %s ) err: %s", e.redisAddr, err)
 		return err
 	}
-	defer c.Close()
+	// c is replaced if a collection script times out
+	defer func() {
+		if c != nil {
+			c.Close()
+		}
+	}()
 
 	log.Debugf("connected to: %s", e.redisAddr)
 	log.Debugf("connecting took %f seconds", connectTookSeconds)
@@ -555,6 +851,8 @@ func (e *Exporter) scrapeRedisHost(ch chan<- prometheus.Metric) error {
 	}
 
 	dbCount := 0
+	maxmemoryPolicy := ""
+	notifyKeyspaceEvents := ""
 	if config, err := redis.Strings(doRedisCmd(c, e.options.ConfigCommandName, "GET", "*")); err == nil {
 		log.Debugf("Redis CONFIG GET * result: [%#v]", config)
 		dbCount, err = e.extractConfigMetrics(ch, config)
@@ -562,6 +860,8 @@ func (e *Exporter) scrapeRedisHost(ch chan<- prometheus.Metric) error {
 			log.Errorf("Redis CONFIG err: %s", err)
 			return err
 		}
+		maxmemoryPolicy = getConfigValue(config, "maxmemory-policy")
+		notifyKeyspaceEvents = getConfigValue(config, "notify-keyspace-events")
 	} else {
 		log.Debugf("Redis CONFIG err: %s", err)
 	}
@@ -577,10 +877,23 @@ func (e *Exporter) scrapeRedisHost(ch chan<- prometheus.Metric) error {
 	}
 	log.Debugf("Redis INFO ALL result: [%#v]", infoAll)
 
+	if maxmemoryPolicy == "" {
+		// CONFIG might be disabled or renamed but INFO reports the policy as well
+		maxmemoryPolicy = getInfoValue(infoAll, "maxmemory_policy")
+	}
+
 	if strings.Contains(infoAll, "cluster_enabled:1") {
 		if clusterInfo, err := redis.String(doRedisCmd(c, "CLUSTER", "INFO")); err == nil {
 			e.extractClusterInfoMetrics(ch, clusterInfo)
 
+			if e.options.InclClusterNodesMetrics {
+				e.extractClusterNodesMetrics(ch, c)
+			}
+
+			if e.options.ExportClusterSlotStats {
+				e.extractClusterSlotStatsMetrics(ch, c)
+			}
+
 			// in cluster mode Redis only supports one database so no extra DB number padding needed
 			dbCount = 1
 		} else {
@@ -597,6 +910,14 @@ func (e *Exporter) scrapeRedisHost(ch chan<- prometheus.Metric) error {
 
 	e.extractInfoMetrics(ch, infoAll, dbCount)
 
+	if e.options.InclMemoryStatsMetrics {
+		e.extractMemoryStatsMetrics(ch, c)
+	}
+
+	if e.options.InclFunctionsMetrics {
+		e.extractFunctionsMetrics(ch, c)
+	}
+
 	e.extractLatencyMetrics(ch, c)
 
 	if e.options.IsCluster {
@@ -608,23 +929,69 @@ func (e *Exporter) scrapeRedisHost(ch chan<- prometheus.Metric) error {
 		defer clusterClient.Close()
 
 		e.extractCheckKeyMetrics(ch, clusterClient)
+		if e.options.CheckKeyFields != "" {
+			e.extractKeyFieldsMetrics(ch, clusterClient)
+		}
+		if e.options.CheckZSetQueues != "" {
+			e.extractZSetQueueMetrics(ch, clusterClient)
+		}
 	} else {
 		e.extractCheckKeyMetrics(ch, c)
+		if e.options.CheckKeyFields != "" {
+			e.extractKeyFieldsMetrics(ch, c)
+		}
+		if e.options.CheckZSetQueues != "" {
+			e.extractZSetQueueMetrics(ch, c)
+		}
 	}
 
 	e.extractSlowLogMetrics(ch, c)
 
 	e.extractStreamMetrics(ch, c)
 
+	e.extractPubSubMetrics(ch, c)
+
 	e.extractCountKeysMetrics(ch, c)
 
-	e.extractKeyGroupMetrics(ch, c, dbCount)
+	if e.options.KeyCountEstimateSamples > 0 {
+		e.extractKeyGroupEstimateMetrics(ch, c, dbCount)
+	} else {
+		e.extractKeyGroupMetrics(ch, c, dbCount)
+	}
+
+	if e.options.CheckBigKeys {
+		e.extractBigKeysMetrics(ch, c, dbCount)
+	}
+
+	if e.options.CheckHotKeys {
+		e.extractHotKeysMetrics(ch, c, dbCount, maxmemoryPolicy)
+	}
+
+	if e.options.CheckTTLDistribution {
+		e.extractKeyTTLMetrics(ch, c, dbCount)
+	}
+
+	if e.options.CheckKeyIdleTime {
+		e.extractKeyIdleTimeMetrics(ch, c, dbCount, maxmemoryPolicy)
+	}
+
+	if e.options.ExportKeyEvents {
+		e.extractKeyEventsMetrics(ch, notifyKeyspaceEvents)
+	}
 
 	if strings.Contains(infoAll, "# Sentinel") {
 		e.extractSentinelMetrics(ch, c)
 	}
 
-	if e.options.ExportClientList {
+	if e.options.ExportACLLog {
+		e.extractACLLogMetrics(ch, c)
+	}
+
+	if e.options.ExportACLUsers {
+		e.extractACLUserMetrics(ch, c)
+	}
+
+	if e.options.ExportClientList || e.options.ExportClientListAggregated {
 		e.extractConnectedClientMetrics(ch, c)
 	}
 
@@ -632,6 +999,21 @@ func (e *Exporter) scrapeRedisHost(ch chan<- prometheus.Metric) error {
 		e.extractTile38Metrics(ch, c)
 	}
 
+	if len(e.luaScripts) > 0 {
+		if c, err = e.extractLuaScriptsMetrics(ch, c); err != nil {
+			log.Errorf("Stopping the scrape: %s", err)
+			return err
+		}
+	}
+
+	if e.options.CollectFunction != "" {
+		e.extractCollectFunctionMetrics(ch, c)
+	}
+
+	if len(e.options.CustomCommands) > 0 {
+		e.extractCustomCommandsMetrics(ch, c)
+	}
+
 	if len(e.options.LuaScript) > 0 {
 		if err := e.extractLuaScriptMetrics(ch, c); err != nil {
 			return err
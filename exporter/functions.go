@@ -0,0 +1,164 @@
+package exporter
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+func (e *Exporter) extractFunctionsMetrics(ch chan<- prometheus.Metric, c redis.Conn) {
+	if reply, err := redis.Values(doRedisCmd(c, "FUNCTION", "LIST")); err == nil {
+		e.handleFunctionListReply(ch, reply)
+	} else {
+		log.Errorf("FUNCTION LIST err: %s", err)
+	}
+
+	if reply, err := doRedisCmd(c, "FUNCTION", "STATS"); err == nil {
+		e.handleFunctionStatsReply(ch, reply)
+	} else {
+		log.Errorf("FUNCTION STATS err: %s", err)
+	}
+}
+
+// handleFunctionListReply handles the reply of FUNCTION LIST which has one entry per library:
+//
+//	[["library_name", "mylib", "engine", "LUA", "functions", [
+//	  ["name", "myfunc", "description", nil, "flags", ["no-writes"]],
+//	]]]
+func (e *Exporter) handleFunctionListReply(ch chan<- prometheus.Metric, reply []interface{}) {
+	for _, l := range reply {
+		lib, err := replyToMap(l)
+		if err != nil {
+			log.Debugf("FUNCTION LIST - couldn't parse library: %s", err)
+			continue
+		}
+		libName, _ := redis.String(lib["library_name"], nil)
+		engine, _ := redis.String(lib["engine"], nil)
+		functions, _ := redis.Values(lib["functions"], nil)
+
+		e.registerConstMetricGauge(ch, "function_library_info", 1, libName, engine)
+		e.registerConstMetricGauge(ch, "function_library_functions", float64(len(functions)), libName, engine)
+
+		for _, f := range functions {
+			fn, err := replyToMap(f)
+			if err != nil {
+				log.Debugf("FUNCTION LIST - couldn't parse function of library %s: %s", libName, err)
+				continue
+			}
+			name, _ := redis.String(fn["name"], nil)
+			flags, _ := redis.Strings(fn["flags"], nil)
+			sort.Strings(flags)
+			e.registerConstMetricGauge(ch, "function_info", 1, libName, name, strings.Join(flags, ","))
+		}
+	}
+}
+
+// handleFunctionStatsReply handles the reply of FUNCTION STATS, the currently running function (if any)
+// and the number of libraries and functions per engine:
+//
+//	["running_script", ["name", "myfunc", "command", ["fcall", "myfunc"], "duration_ms", 8021],
+//	 "engines", ["LUA", ["libraries_count", 1, "functions_count", 1]]]
+func (e *Exporter) handleFunctionStatsReply(ch chan<- prometheus.Metric, reply interface{}) {
+	stats, err := replyToMap(reply)
+	if err != nil {
+		log.Debugf("FUNCTION STATS - couldn't parse reply: %s", err)
+		return
+	}
+
+	running := 0.0
+	if stats["running_script"] != nil {
+		if script, err := replyToMap(stats["running_script"]); err == nil {
+			running = 1
+			name, _ := redis.String(script["name"], nil)
+			if durationMs, err := replyToFloat64(script["duration_ms"]); err == nil {
+				e.registerConstMetricGauge(ch, "function_running_duration_seconds", durationMs/1e3, name)
+			}
+		}
+	}
+	e.registerConstMetricGauge(ch, "function_running", running)
+
+	engines, err := replyToMap(stats["engines"])
+	if err != nil {
+		log.Debugf("FUNCTION STATS - couldn't parse engines: %s", err)
+		return
+	}
+	for engine, v := range engines {
+		engineStats, err := replyToMap(v)
+		if err != nil {
+			continue
+		}
+		if val, err := replyToFloat64(engineStats["libraries_count"]); err == nil {
+			e.registerConstMetricGauge(ch, "function_engine_libraries", val, engine)
+		}
+		if val, err := replyToFloat64(engineStats["functions_count"]); err == nil {
+			e.registerConstMetricGauge(ch, "function_engine_functions", val, engine)
+		}
+	}
+}
+
+// extractCollectFunctionMetrics calls the collect function with FCALL_RO, the reply uses the same format as
+// the scripts of the script directory and the metrics are prefixed with collect-function-prefix. Its success
+// and duration aren't exported as script_* metrics so they can't collide with a script of the same name.
+func (e *Exporter) extractCollectFunctionMetrics(ch chan<- prometheus.Metric, c redis.Conn) {
+	name := e.options.CollectFunction
+
+	start := time.Now()
+	reply, err := e.callCollectFunction(c)
+	e.registerConstMetricGauge(ch, "function_collect_duration_seconds", time.Since(start).Seconds(), name)
+
+	var metrics []scriptMetric
+	if err == nil {
+		metrics, err = parseScriptMetrics(reply)
+	}
+	if err != nil {
+		log.Errorf("Collect function %s err: %s", name, err)
+		e.registerConstMetricGauge(ch, "function_collect_success", 0, name)
+		return
+	}
+
+	e.registerConstMetricGauge(ch, "function_collect_success", 1, name)
+	e.registerScriptMetrics(ch, e.options.CollectFunctionPrefix, metrics)
+}
+
+// callCollectFunction loads the library once and again whenever the function is missing,
+// e.g. after a restart of Redis or a FUNCTION FLUSH
+func (e *Exporter) callCollectFunction(c redis.Conn) (interface{}, error) {
+	// the previous collectors leave the connection in any database
+	if _, err := doRedisCmd(c, "SELECT", e.options.CollectFunctionDB); err != nil {
+		return nil, err
+	}
+
+	if len(e.options.CollectFunctionLibrary) > 0 && !e.collectFunctionLoaded {
+		if err := e.loadCollectFunctionLibrary(c); err != nil {
+			return nil, err
+		}
+	}
+
+	reply, err := doRedisCmd(c, "FCALL_RO", e.options.CollectFunction, 0)
+	if len(e.options.CollectFunctionLibrary) > 0 && isFunctionNotFoundErr(err) {
+		if err := e.loadCollectFunctionLibrary(c); err != nil {
+			return nil, err
+		}
+		reply, err = doRedisCmd(c, "FCALL_RO", e.options.CollectFunction, 0)
+	}
+	return reply, err
+}
+
+func (e *Exporter) loadCollectFunctionLibrary(c redis.Conn) error {
+	lib, err := redis.String(doRedisCmd(c, "FUNCTION", "LOAD", "REPLACE", e.options.CollectFunctionLibrary))
+	if err != nil {
+		return err
+	}
+	log.Debugf("Loaded function library %s", lib)
+	e.collectFunctionLoaded = true
+	return nil
+}
+
+func isFunctionNotFoundErr(err error) bool {
+	rErr, ok := err.(redis.Error)
+	return ok && strings.Contains(strings.ToLower(string(rErr)), "function not found")
+}
@@ -0,0 +1,209 @@
+package exporter
+
+import (
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// collectGauges runs fn and returns the values of the gauges it sent, keyed by metric name and labels
+func collectGauges(fn func(ch chan<- prometheus.Metric)) map[string]float64 {
+	chM := make(chan prometheus.Metric)
+	go func() {
+		fn(chM)
+		close(chM)
+	}()
+
+	res := map[string]float64{}
+	for m := range chM {
+		got := &dto.Metric{}
+		m.Write(got)
+
+		name := strings.TrimPrefix(strings.Split(strings.Split(m.Desc().String(), `fqName: "`)[1], `"`)[0], "test_")
+		var lbls []string
+		for _, l := range got.GetLabel() {
+			lbls = append(lbls, l.GetName()+`="`+l.GetValue()+`"`)
+		}
+		if len(lbls) > 0 {
+			name += "{" + strings.Join(lbls, ",") + "}"
+		}
+		res[name] = got.GetGauge().GetValue()
+	}
+	return res
+}
+
+func TestCollectFunctionPrefix(t *testing.T) {
+	e, _ := NewRedisExporter("", Options{CollectFunction: "collect"})
+	if e.options.CollectFunctionPrefix != "collect" {
+		t.Errorf("want the function name as prefix, got: %s", e.options.CollectFunctionPrefix)
+	}
+
+	e, _ = NewRedisExporter("", Options{CollectFunction: "collect", CollectFunctionPrefix: "app"})
+	if e.options.CollectFunctionPrefix != "app" {
+		t.Errorf("want the prefix app, got: %s", e.options.CollectFunctionPrefix)
+	}
+}
+
+func TestHandleFunctionReplies(t *testing.T) {
+	e, _ := NewRedisExporter("", Options{Namespace: "test"})
+
+	list := []interface{}{
+		[]interface{}{
+			[]byte("library_name"), []byte("mylib"),
+			[]byte("engine"), []byte("LUA"),
+			[]byte("functions"), []interface{}{
+				[]interface{}{[]byte("name"), []byte("myfunc"), []byte("description"), nil, []byte("flags"), []interface{}{[]byte("no-writes"), []byte("allow-stale")}},
+				[]interface{}{[]byte("name"), []byte("otherfunc"), []byte("description"), []byte("does things"), []byte("flags"), []interface{}{}},
+			},
+		},
+	}
+	stats := []interface{}{
+		[]byte("running_script"), []interface{}{
+			[]byte("name"), []byte("myfunc"),
+			[]byte("command"), []interface{}{[]byte("fcall"), []byte("myfunc"), []byte("0")},
+			[]byte("duration_ms"), int64(8021),
+		},
+		[]byte("engines"), []interface{}{
+			[]byte("LUA"), []interface{}{[]byte("libraries_count"), int64(1), []byte("functions_count"), int64(2)},
+		},
+	}
+
+	got := collectGauges(func(ch chan<- prometheus.Metric) {
+		e.handleFunctionListReply(ch, list)
+		e.handleFunctionStatsReply(ch, stats)
+	})
+	want := map[string]float64{
+		`function_library_info{engine="LUA",library="mylib"}`:                            1,
+		`function_library_functions{engine="LUA",library="mylib"}`:                       2,
+		`function_info{flags="allow-stale,no-writes",function="myfunc",library="mylib"}`: 1,
+		`function_info{flags="",function="otherfunc",library="mylib"}`:                   1,
+		`function_running`: 1,
+		`function_running_duration_seconds{name="myfunc"}`: 8.021,
+		`function_engine_libraries{engine="LUA"}`:          1,
+		`function_engine_functions{engine="LUA"}`:          2,
+	}
+	for k, v := range want {
+		if val, ok := got[k]; !ok || val != v {
+			t.Errorf("metric %s: want %f, got %f (found: %t)", k, v, val, ok)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("unexpected metrics: %#v", got)
+	}
+
+	// nothing running
+	got = collectGauges(func(ch chan<- prometheus.Metric) {
+		e.handleFunctionStatsReply(ch, []interface{}{[]byte("running_script"), nil, []byte("engines"), []interface{}{}})
+	})
+	if len(got) != 1 || got["function_running"] != 0 {
+		t.Errorf("unexpected metrics: %#v", got)
+	}
+}
+
+func TestFunctionsMetrics(t *testing.T) {
+	if os.Getenv("TEST_REDIS_URI") == "" {
+		t.Skipf("TEST_REDIS_URI not set - skipping")
+	}
+	addr := os.Getenv("TEST_REDIS_URI")
+
+	c, err := redis.DialURL(addr)
+	if err != nil {
+		t.Fatalf("Couldn't connect to %#v: %#v", addr, err)
+	}
+	defer c.Close()
+
+	lib := "#!lua name=exporter_test_lib\nredis.register_function{function_name='exporter_test_func', callback=function() return 1 end, flags={'no-writes'}}"
+	if _, err := doRedisCmd(c, "FUNCTION", "LOAD", "REPLACE", lib); err != nil {
+		t.Skipf("FUNCTION LOAD not supported: %s", err)
+	}
+	defer doRedisCmd(c, "FUNCTION", "DELETE", "exporter_test_lib")
+
+	e, _ := NewRedisExporter(addr, Options{Namespace: "test", Registry: prometheus.NewRegistry(), InclFunctionsMetrics: true})
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+
+	body := downloadURL(t, ts.URL+"/metrics")
+	for _, want := range []string{
+		`test_function_library_info{engine="LUA",library="exporter_test_lib"} 1`,
+		`test_function_info{flags="no-writes",function="exporter_test_func",library="exporter_test_lib"} 1`,
+		`test_function_running 0`,
+		`test_function_engine_libraries{engine="LUA"}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("want metrics to include %s, have:\n%s", want, body)
+		}
+	}
+}
+
+func TestIsFunctionNotFoundErr(t *testing.T) {
+	for _, tst := range []struct {
+		err  error
+		want bool
+	}{
+		{err: redis.Error("ERR Function not found"), want: true},
+		{err: redis.Error("ERR unknown command 'FCALL_RO'"), want: false},
+		{err: nil, want: false},
+	} {
+		if got := isFunctionNotFoundErr(tst.err); got != tst.want {
+			t.Errorf("isFunctionNotFoundErr(%v): want %t, got %t", tst.err, tst.want, got)
+		}
+	}
+}
+
+func TestCollectFunctionMetrics(t *testing.T) {
+	if os.Getenv("TEST_REDIS_URI") == "" {
+		t.Skipf("TEST_REDIS_URI not set - skipping")
+	}
+	addr := os.Getenv("TEST_REDIS_URI")
+
+	c, err := redis.DialURL(addr)
+	if err != nil {
+		t.Fatalf("Couldn't connect to %#v: %#v", addr, err)
+	}
+	defer c.Close()
+
+	if _, err := doRedisCmd(c, "FUNCTION", "LIST"); err != nil {
+		t.Skipf("Functions not supported: %s", err)
+	}
+	defer doRedisCmd(c, "FUNCTION", "DELETE", "exporter_collect_lib")
+
+	lib := `#!lua name=exporter_collect_lib
+redis.register_function{function_name='exporter_collect', flags={'no-writes'}, callback=function()
+  return cjson.encode({{name = 'collected', type = 'counter', labels = {source = 'function'}, value = 42}})
+end}`
+
+	e, _ := NewRedisExporter(addr, Options{Namespace: "test", Registry: prometheus.NewRegistry(), CollectFunction: "exporter_collect", CollectFunctionLibrary: []byte(lib)})
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+
+	wants := []string{
+		`test_exporter_collect_collected{source="function"} 42`,
+		`test_function_collect_success{function="exporter_collect"} 1`,
+		`test_function_collect_duration_seconds{function="exporter_collect"}`,
+	}
+	body := downloadURL(t, ts.URL+"/metrics")
+	for _, want := range wants {
+		if !strings.Contains(body, want) {
+			t.Errorf("want metrics to include %s, have:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "test_script_success") {
+		t.Errorf("want the collect function not to be exported as script, have:\n%s", body)
+	}
+
+	// the library is loaded again if it's gone
+	if _, err := doRedisCmd(c, "FUNCTION", "DELETE", "exporter_collect_lib"); err != nil {
+		t.Fatalf("FUNCTION DELETE err: %s", err)
+	}
+	body = downloadURL(t, ts.URL+"/metrics")
+	for _, want := range wants {
+		if !strings.Contains(body, want) {
+			t.Errorf("want metrics to include %s after FUNCTION DELETE, have:\n%s", want, body)
+		}
+	}
+}
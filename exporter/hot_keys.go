@@ -0,0 +1,63 @@
+package exporter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// OBJECT FREQ only works when the access frequency is tracked, i.e. with one of the LFU eviction policies
+func isLFUPolicy(policy string) bool {
+	return strings.HasSuffix(policy, "-lfu")
+}
+
+func (e *Exporter) extractHotKeysMetrics(ch chan<- prometheus.Metric, c redis.Conn, dbCount int, maxmemoryPolicy string) {
+	if !isLFUPolicy(maxmemoryPolicy) {
+		log.Debugf("Hot key detection not supported with maxmemory-policy %q, requires an LFU policy", maxmemoryPolicy)
+		e.hotKeys = nil
+		e.registerConstMetricGauge(ch, "hot_keys_supported", 0)
+		return
+	}
+	e.registerConstMetricGauge(ch, "hot_keys_supported", 1)
+
+	if e.hotKeys == nil {
+		e.hotKeys = map[int]*sampledTopKeys{}
+	}
+
+	for db := 0; db < dbCount; db++ {
+		if _, err := doRedisCmd(c, "SELECT", db); err != nil {
+			log.Errorf("Couldn't select database %d when sampling hot keys.", db)
+			continue
+		}
+		if size, err := redis.Int64(doRedisCmd(c, "DBSIZE")); err != nil || size == 0 {
+			delete(e.hotKeys, db)
+			continue
+		}
+
+		state, ok := e.hotKeys[db]
+		if !ok {
+			state = newSampledTopKeys()
+			e.hotKeys[db] = state
+		}
+
+		cursor, err := sampleKeys(c, state.cursor, e.newKeySampleBudget(), []string{sampleAttrFreq}, nil, state.add)
+		if err != nil {
+			log.Errorf("Error sampling hot keys for db %d: %s", db, err)
+			continue
+		}
+		state.setCursor(cursor)
+		state.trim(
+			e.options.HotKeysTopN,
+			func(k sampledKey) string { return "" },
+			func(k sampledKey) float64 { return k.freq },
+		)
+
+		dbLabel := fmt.Sprintf("db%d", db)
+		for _, entry := range state.top {
+			e.registerConstMetricGauge(ch, "hot_key_frequency", entry.freq, dbLabel, entry.key)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package exporter
+
+import (
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestHotKeysNotSupported(t *testing.T) {
+	for _, policy := range []string{"noeviction", "allkeys-lru", ""} {
+		e, _ := NewRedisExporter("", Options{Namespace: "test", CheckHotKeys: true})
+
+		chM := make(chan prometheus.Metric)
+		go func() {
+			// the policy check happens before any command is sent so no connection is needed
+			e.extractHotKeysMetrics(chM, nil, 16, policy)
+			close(chM)
+		}()
+
+		cnt := 0
+		for m := range chM {
+			cnt++
+			if !strings.Contains(m.Desc().String(), "hot_keys_supported") {
+				t.Errorf("unexpected metric: %s", m.Desc().String())
+				continue
+			}
+			got := &dto.Metric{}
+			m.Write(got)
+			if got.GetGauge().GetValue() != 0 {
+				t.Errorf("expected hot_keys_supported to be 0 for policy %q", policy)
+			}
+		}
+		if cnt != 1 {
+			t.Errorf("expected exactly one metric for policy %q, got: %d", policy, cnt)
+		}
+	}
+}
+
+func TestGetMaxmemoryPolicy(t *testing.T) {
+	if v := getConfigValue([]string{"maxmemory", "0", "maxmemory-policy", "allkeys-lfu"}, "maxmemory-policy"); v != "allkeys-lfu" || !isLFUPolicy(v) {
+		t.Errorf("unexpected maxmemory-policy from config: %s", v)
+	}
+	if v := getInfoValue("# Memory\r\nmaxmemory:0\r\nmaxmemory_policy:volatile-lru\r\n", "maxmemory_policy"); v != "volatile-lru" || isLFUPolicy(v) {
+		t.Errorf("unexpected maxmemory_policy from info: %s", v)
+	}
+}
+
+func TestHotKeysMetrics(t *testing.T) {
+	if os.Getenv("TEST_REDIS_URI") == "" {
+		t.Skipf("TEST_REDIS_URI not set - skipping")
+	}
+	addr := os.Getenv("TEST_REDIS_URI")
+
+	c, err := redis.DialURL(addr)
+	if err != nil {
+		t.Fatalf("Couldn't connect to %#v: %#v", addr, err)
+	}
+	defer c.Close()
+
+	policy, err := redis.Strings(doRedisCmd(c, "CONFIG", "GET", "maxmemory-policy"))
+	if err != nil || len(policy) != 2 {
+		t.Fatalf("Couldn't get maxmemory-policy: %s", err)
+	}
+	if _, err := doRedisCmd(c, "CONFIG", "SET", "maxmemory-policy", "allkeys-lfu"); err != nil {
+		t.Fatalf("Couldn't set maxmemory-policy: %s", err)
+	}
+	defer doRedisCmd(c, "CONFIG", "SET", "maxmemory-policy", policy[1])
+
+	setupDBKeys(t, addr)
+	defer deleteKeysFromDB(t, addr)
+
+	if _, err := doRedisCmd(c, "SELECT", dbNumStr); err != nil {
+		t.Fatalf("Couldn't select database %s: %s", dbNumStr, err)
+	}
+	for i := 0; i < 1000; i++ {
+		doRedisCmd(c, "LLEN", "beatles_list")
+	}
+
+	e, _ := NewRedisExporter(addr, Options{Namespace: "test", Registry: prometheus.NewRegistry(), CheckHotKeys: true, HotKeysTopN: 1})
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+
+	body := downloadURL(t, ts.URL+"/metrics")
+	for _, want := range []string{
+		`test_hot_keys_supported 1`,
+		`test_hot_key_frequency{db="db11",key="beatles_list"}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("want metrics to include %s, have:\n%s", want, body)
+		}
+	}
+}
@@ -5,11 +5,57 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// collectorState is the state of the collectors that continue where they stopped during the previous scrape
+type collectorState struct {
+	bigKeys map[int]*bigKeysDBState
+	hotKeys map[int]*sampledTopKeys
+	keyTTLs map[int]*keyTTLDBState
+	keyIdle map[int]*keyIdleTimeDBState
+
+	// ACL LOG entries seen so far and the resulting denial counters
+	aclLog *aclLogState
+}
+
+// states of targets that weren't scraped for that long are dropped
+const targetStateMaxAge = time.Hour
+
+// targetState is the collector state of a target of /scrape, the lock is held while the target is scraped
+type targetState struct {
+	sync.Mutex
+	collectorState
+	lastScrape time.Time
+}
+
+type targetStates struct {
+	sync.Mutex
+	states map[string]*targetState
+}
+
+// get returns the state of target, it's created if the target wasn't scraped before
+func (t *targetStates) get(target string, now time.Time) *targetState {
+	t.Lock()
+	defer t.Unlock()
+	for k, s := range t.states {
+		if k != target && now.Sub(s.lastScrape) > targetStateMaxAge {
+			delete(t.states, k)
+		}
+	}
+	s, ok := t.states[target]
+	if !ok {
+		s = &targetState{}
+		t.states[target] = s
+	}
+	s.lastScrape = now
+	return s
+}
+
 func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	e.mux.ServeHTTP(w, r)
 }
@@ -62,6 +108,14 @@ func (e *Exporter) scrapeHandler(w http.ResponseWriter, r *http.Request) {
 		opts.CheckSingleKeys = csk
 	}
 
+	if ckf := r.URL.Query().Get("check-key-fields"); ckf != "" {
+		opts.CheckKeyFields = ckf
+	}
+
+	if czq := r.URL.Query().Get("check-zset-queues"); czq != "" {
+		opts.CheckZSetQueues = czq
+	}
+
 	if cs := r.URL.Query().Get("check-streams"); cs != "" {
 		opts.CheckStreams = cs
 	}
@@ -70,21 +124,41 @@ func (e *Exporter) scrapeHandler(w http.ResponseWriter, r *http.Request) {
 		opts.CheckSingleStreams = css
 	}
 
+	if cpc := r.URL.Query().Get("check-pubsub-channels"); cpc != "" {
+		opts.CheckPubSubChannels = cpc
+	}
+
+	if cspc := r.URL.Query().Get("check-single-pubsub-channels"); cspc != "" {
+		opts.CheckSinglePubSubChannels = cspc
+	}
+
 	if cntk := r.URL.Query().Get("count-keys"); cntk != "" {
 		opts.CountKeys = cntk
 	}
 
+	// the key events subscriber runs in the background and needs an exporter that outlives the request
+	opts.ExportKeyEvents = false
+
 	registry := prometheus.NewRegistry()
 	opts.Registry = registry
 
-	_, err = NewRedisExporter(target, opts)
+	exp, err := NewRedisExporter(target, opts)
 	if err != nil {
 		http.Error(w, "NewRedisExporter() err: err", http.StatusBadRequest)
 		e.targetScrapeRequestErrors.Inc()
 		return
 	}
+	// the exporter only lives for this request, the offsets and the collector state of the previous scrapes are
+	// kept per target so e.g. the SCAN cursors of the sampling collectors continue where they stopped
+	exp.replOffsets = e.replOffsets
+	state := e.targetStates.get(target, time.Now())
+	state.Lock()
+	defer state.Unlock()
+	exp.collectorState = state.collectorState
 
 	promhttp.HandlerFor(
 		registry, promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError},
 	).ServeHTTP(w, r)
+
+	state.collectorState = exp.collectorState
 }
@@ -12,6 +12,7 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
@@ -306,3 +307,43 @@ func downloadURLWithStatusCode(t *testing.T, u string) (int, string) {
 
 	return resp.StatusCode, string(body)
 }
+
+func TestTargetStates(t *testing.T) {
+	states := &targetStates{states: map[string]*targetState{}}
+	now := time.Now()
+
+	s := states.get("redis://host-1:6379", now)
+	s.aclLog = newACLLogState()
+	if got := states.get("redis://host-1:6379", now.Add(time.Minute)); got != s || got.aclLog == nil {
+		t.Errorf("want the state of the previous scrape of the same target, got: %#v", got)
+	}
+	if got := states.get("redis://host-2:6379", now.Add(time.Minute)); got == s {
+		t.Errorf("want a different state for a different target")
+	}
+
+	// the state of a target that wasn't scraped for a while is dropped
+	states.get("redis://host-2:6379", now.Add(time.Minute+2*targetStateMaxAge))
+	if _, ok := states.states["redis://host-1:6379"]; ok {
+		t.Errorf("expected the state of host-1 to be dropped")
+	}
+}
+
+func TestScrapeKeepsCollectorState(t *testing.T) {
+	if os.Getenv("TEST_REDIS_URI") == "" {
+		t.Skipf("TEST_REDIS_URI not set - skipping")
+	}
+	addr := os.Getenv("TEST_REDIS_URI")
+
+	e, _ := NewRedisExporter("", Options{Namespace: "test", Registry: prometheus.NewRegistry(), CheckBigKeys: true})
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+
+	downloadURL(t, ts.URL+"/scrape?target="+url.QueryEscape(addr))
+
+	u, _ := url.Parse(addr)
+	u.User = nil
+	state, ok := e.targetStates.states[u.String()]
+	if !ok || state.bigKeys == nil {
+		t.Errorf("expected the big keys state of %s to be kept, got: %#v", u.String(), e.targetStates.states)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -126,6 +127,85 @@ func (e *Exporter) extractInfoMetrics(ch chan<- prometheus.Metric, info string,
 			keyValues["master_port"],
 			keyValues["slave_read_only"])
 	}
+
+	if keyValues["role"] == "master" {
+		e.extractReplicaLagMetrics(ch, keyValues)
+	}
+}
+
+type replOffsetSample struct {
+	replID string
+	offset float64
+	ts     time.Time
+}
+
+// samples of targets that weren't scraped for that long are dropped
+const replOffsetSampleMaxAge = time.Hour
+
+// replOffsetSamples are the master replication offsets of the previous scrape per target
+type replOffsetSamples struct {
+	sync.Mutex
+	samples map[string]*replOffsetSample
+}
+
+// updateMasterReplOffsetRate remembers the current master replication offset and returns
+// the growth rate in bytes per second since the offset seen during the previous scrape of the same target.
+func (e *Exporter) updateMasterReplOffsetRate(replID string, offset float64, now time.Time) (rate float64, ok bool) {
+	e.replOffsets.Lock()
+	for addr, s := range e.replOffsets.samples {
+		if now.Sub(s.ts) > replOffsetSampleMaxAge {
+			delete(e.replOffsets.samples, addr)
+		}
+	}
+	prev := e.replOffsets.samples[e.redisAddr]
+	e.replOffsets.samples[e.redisAddr] = &replOffsetSample{replID: replID, offset: offset, ts: now}
+	e.replOffsets.Unlock()
+
+	// no previous sample or the replication history changed (e.g. restart or failover)
+	if prev == nil || prev.replID != replID || offset < prev.offset {
+		return 0, false
+	}
+
+	elapsed := now.Sub(prev.ts).Seconds()
+	if elapsed <= 0 {
+		return 0, false
+	}
+	return (offset - prev.offset) / elapsed, true
+}
+
+/*
+	the lag is calculated from these fields:
+		slave0:ip=10.254.11.1,port=6379,state=online,offset=1751844676,lag=0
+		master_repl_offset:1751844700
+*/
+func (e *Exporter) extractReplicaLagMetrics(ch chan<- prometheus.Metric, keyValues map[string]string) {
+	masterOffset, err := strconv.ParseFloat(keyValues["master_repl_offset"], 64)
+	if err != nil {
+		log.Debugf("Can not parse master_repl_offset, got: %s", keyValues["master_repl_offset"])
+		return
+	}
+
+	rate, rateOk := e.updateMasterReplOffsetRate(keyValues["master_replid"], masterOffset, time.Now())
+
+	for fieldKey, fieldValue := range keyValues {
+		slaveOffset, slaveIP, slavePort, slaveState, _, ok := parseConnectedSlaveString(fieldKey, fieldValue)
+		if !ok {
+			continue
+		}
+
+		lagBytes := masterOffset - slaveOffset
+		if lagBytes < 0 {
+			lagBytes = 0
+		}
+		e.registerConstMetricGauge(ch, "connected_replica_lag_bytes", lagBytes, slaveIP, slavePort, slaveState)
+
+		switch {
+		case lagBytes == 0:
+			e.registerConstMetricGauge(ch, "connected_replica_lag_estimated_seconds", 0, slaveIP, slavePort, slaveState)
+		case rateOk && rate > 0:
+			e.registerConstMetricGauge(ch, "connected_replica_lag_estimated_seconds", lagBytes/rate, slaveIP, slavePort, slaveState)
+		}
+	}
 }
 
 func (e *Exporter) extractClusterInfoMetrics(ch chan<- prometheus.Metric, info string) {
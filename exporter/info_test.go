@@ -7,8 +7,10 @@ import (
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -84,6 +86,104 @@ func TestParseConnectedSlaveString(t *testing.T) {
 	}
 }
 
+func TestReplicaLagMetrics(t *testing.T) {
+	e, _ := NewRedisExporter("", Options{Namespace: "test"})
+
+	info := "# Replication\r\nrole:master\r\nconnected_slaves:2\r\n" +
+		"slave0:ip=10.0.0.1,port=6379,state=online,offset=9000,lag=0\r\n" +
+		"slave1:ip=10.0.0.2,port=6379,state=online,offset=10000,lag=1\r\n" +
+		"master_replid:8f6e5d4c3b2a\r\nmaster_repl_offset:10000\r\n"
+
+	// pretend the previous scrape happened 10 seconds ago when the offset was 5000, i.e. 500 bytes/sec
+	e.replOffsets.samples[""] = &replOffsetSample{replID: "8f6e5d4c3b2a", offset: 5000, ts: time.Now().Add(-10 * time.Second)}
+
+	chM := make(chan prometheus.Metric)
+	go func() {
+		e.extractInfoMetrics(chM, info, 0)
+		close(chM)
+	}()
+
+	want := map[string]float64{
+		`connected_replica_lag_bytes-10.0.0.1`:             1000,
+		`connected_replica_lag_bytes-10.0.0.2`:             0,
+		`connected_replica_lag_estimated_seconds-10.0.0.1`: 2,
+		`connected_replica_lag_estimated_seconds-10.0.0.2`: 0,
+	}
+	for m := range chM {
+		desc := m.Desc().String()
+		if !strings.Contains(desc, "connected_replica_lag") {
+			continue
+		}
+		got := &dto.Metric{}
+		m.Write(got)
+
+		name := "connected_replica_lag_bytes"
+		if strings.Contains(desc, "connected_replica_lag_estimated_seconds") {
+			name = "connected_replica_lag_estimated_seconds"
+		}
+		for _, l := range got.GetLabel() {
+			if l.GetName() == "slave_ip" {
+				name += "-" + l.GetValue()
+			}
+		}
+
+		wantVal, ok := want[name]
+		if !ok {
+			t.Errorf("unexpected metric: %s", name)
+			continue
+		}
+		if val := got.GetGauge().GetValue(); val < wantVal-0.1 || val > wantVal+0.1 {
+			t.Errorf("metric %s: want %f, got %f", name, wantVal, val)
+		}
+		delete(want, name)
+	}
+	for k := range want {
+		t.Errorf("didn't find %s", k)
+	}
+
+	if s := e.replOffsets.samples[""]; s == nil || s.offset != 10000 {
+		t.Errorf("expected the master offset to be remembered for the next scrape, got: %#v", s)
+	}
+}
+
+func TestUpdateMasterReplOffsetRate(t *testing.T) {
+	e, _ := NewRedisExporter("redis://master-1:6379", Options{})
+	now := time.Now()
+
+	if _, ok := e.updateMasterReplOffsetRate("abc", 100, now); ok {
+		t.Errorf("expected no rate without a previous sample")
+	}
+	if rate, ok := e.updateMasterReplOffsetRate("abc", 300, now.Add(2*time.Second)); !ok || rate != 100 {
+		t.Errorf("expected a rate of 100, got: %f (ok: %t)", rate, ok)
+	}
+	if _, ok := e.updateMasterReplOffsetRate("def", 400, now.Add(4*time.Second)); ok {
+		t.Errorf("expected no rate after the replication id changed")
+	}
+	if _, ok := e.updateMasterReplOffsetRate("def", 10, now.Add(6*time.Second)); ok {
+		t.Errorf("expected no rate after the offset went backwards")
+	}
+
+	// the exporters of /scrape share the offsets, they're kept per target
+	other, _ := NewRedisExporter("redis://master-2:6379", Options{})
+	other.replOffsets = e.replOffsets
+	if _, ok := other.updateMasterReplOffsetRate("def", 1000, now.Add(8*time.Second)); ok {
+		t.Errorf("expected no rate without a previous sample of the same target")
+	}
+	next, _ := NewRedisExporter("redis://master-1:6379", Options{})
+	next.replOffsets = e.replOffsets
+	if rate, ok := next.updateMasterReplOffsetRate("def", 50, now.Add(8*time.Second)); !ok || rate != 20 {
+		t.Errorf("expected a rate of 20, got: %f (ok: %t)", rate, ok)
+	}
+
+	// samples of targets that weren't scraped for a while are dropped
+	if _, ok := e.updateMasterReplOffsetRate("def", 100, now.Add(2*replOffsetSampleMaxAge)); ok {
+		t.Errorf("expected no rate after the sample expired")
+	}
+	if _, ok := e.replOffsets.samples["redis://master-2:6379"]; ok {
+		t.Errorf("expected the expired sample of the other target to be dropped")
+	}
+}
+
 func TestCommandStats(t *testing.T) {
 	defaultAddr := os.Getenv("TEST_REDIS_URI")
 	redisSixTwoAddr := os.Getenv("TEST_REDIS6_URI")
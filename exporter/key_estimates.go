@@ -0,0 +1,256 @@
+package exporter
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// randomKeysScript samples ARGV[1] keys with RANDOMKEY, every sample is returned as {key, key group, memory usage},
+// the key group is only set if key group patterns are passed starting at ARGV[3] and the memory usage only if ARGV[2] is "1"
+var randomKeysScript = redis.NewScript(0, keyGroupLuaFunctions+`
+local n = tonumber(ARGV[1])
+local with_memory = ARGV[2] == "1"
+local grouped = #ARGV >= 3
+if grouped then
+  check_key_group_patterns(3)
+end
+local result = {}
+for i=1,n do
+  local key = redis.call("RANDOMKEY")
+  if not key then
+    break
+  end
+  local group = ""
+  if grouped then
+    group = get_key_group(key, 3)
+  end
+  local usage = 0
+  if with_memory then
+    usage = redis.call("MEMORY", "USAGE", key) or 0
+  end
+  result[#result+1] = {key, group, usage}
+end
+return result
+`)
+
+type randomKeySample struct {
+	key         string
+	keyGroup    string
+	memoryUsage int64
+}
+
+// sampleRandomKeys returns up to n random keys of the current database, keys can be sampled more than once
+func sampleRandomKeys(c redis.Conn, n int64, withMemory bool, keyGroups []string) ([]randomKeySample, error) {
+	args := []interface{}{n, "0"}
+	if withMemory {
+		args[1] = "1"
+	}
+	for _, g := range keyGroups {
+		args = append(args, g)
+	}
+
+	reply, err := redis.Values(randomKeysScript.Do(c, args...))
+	if err != nil {
+		return nil, err
+	}
+	res := make([]randomKeySample, 0, len(reply))
+	for _, r := range reply {
+		values, err := redis.Values(r, nil)
+		if err != nil || len(values) != 3 {
+			return nil, fmt.Errorf("invalid sample: %v", r)
+		}
+		s := randomKeySample{}
+		s.key, _ = redis.String(values[0], nil)
+		s.keyGroup, _ = redis.String(values[1], nil)
+		s.memoryUsage, _ = redis.Int64(values[2], nil)
+		res = append(res, s)
+	}
+	return res, nil
+}
+
+// estimateCount scales the share of the samples that matched to the size of the database and returns the
+// estimated count and its standard deviation. If none or all of the samples matched the standard deviation
+// would be 0 although the count isn't known exactly, DBSIZE / samples is used as its upper bound instead.
+func estimateCount(matched int, samples int, dbSize int64) (float64, float64) {
+	if samples == 0 {
+		return 0, 0
+	}
+	p := float64(matched) / float64(samples)
+	if matched == 0 || matched == samples {
+		return p * float64(dbSize), float64(dbSize) / float64(samples)
+	}
+	return p * float64(dbSize), float64(dbSize) * math.Sqrt(p*(1-p)/float64(samples))
+}
+
+// globToRegexp converts a glob-style pattern as used by SCAN's MATCH option into a regex
+func globToRegexp(pattern string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString("^")
+	inClass := false
+	for i := 0; i < len(pattern); i++ {
+		ch := pattern[i]
+		switch {
+		case ch == '\\' && i+1 < len(pattern):
+			i++
+			sb.WriteString(regexp.QuoteMeta(string(pattern[i])))
+		case inClass:
+			if ch == ']' {
+				inClass = false
+			}
+			sb.WriteByte(ch)
+		case ch == '*':
+			sb.WriteString("(?s:.*)")
+		case ch == '?':
+			sb.WriteString("(?s:.)")
+		case ch == '[':
+			inClass = true
+			sb.WriteByte(ch)
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
+
+// extractCountKeysEstimateMetrics estimates the counts of the count-keys patterns from random samples of each
+// database instead of scanning the whole keyspace, patterns with a type are still counted with SCAN
+func (e *Exporter) extractCountKeysEstimateMetrics(ch chan<- prometheus.Metric, c redis.Conn, cntKeys []dbKeyPair) {
+	var dbs []string
+	patterns := map[string][]string{}
+	for _, k := range cntKeys {
+		if pattern, keyType := splitCountKeysType(k.key); keyType != "" {
+			if _, err := doRedisCmd(c, "SELECT", k.db); err != nil {
+				log.Errorf("Couldn't select database '%s' when counting keys", k.db)
+				continue
+			}
+			e.extractCountKeysByTypeMetrics(ch, c, "db"+k.db, pattern, keyType)
+			continue
+		}
+		if _, ok := patterns[k.db]; !ok {
+			dbs = append(dbs, k.db)
+		}
+		patterns[k.db] = append(patterns[k.db], k.key)
+	}
+
+	for _, db := range dbs {
+		if _, err := doRedisCmd(c, "SELECT", db); err != nil {
+			log.Errorf("Couldn't select database '%s' when estimating key counts", db)
+			continue
+		}
+		dbSize, err := redis.Int64(doRedisCmd(c, "DBSIZE"))
+		if err != nil {
+			log.Errorf("DBSIZE err: %s", err)
+			continue
+		}
+		samples, err := sampleRandomKeys(c, e.options.KeyCountEstimateSamples, false, nil)
+		if err != nil {
+			log.Errorf("Couldn't sample the keys of database '%s', err: %s", db, err)
+			continue
+		}
+
+		dbLabel := "db" + db
+		e.registerConstMetricGauge(ch, "keys_count_estimate_samples", float64(len(samples)), dbLabel)
+		for _, pattern := range patterns[db] {
+			re, err := globToRegexp(pattern)
+			if err != nil {
+				log.Errorf("Couldn't convert pattern '%s', err: %s", pattern, err)
+				continue
+			}
+			matched := 0
+			for _, s := range samples {
+				if re.MatchString(s.key) {
+					matched++
+				}
+			}
+			est, stddev := estimateCount(matched, len(samples), dbSize)
+			e.registerConstMetricGauge(ch, "keys_count_estimate", est, dbLabel, pattern)
+			e.registerConstMetricGauge(ch, "keys_count_estimate_stddev", stddev, dbLabel, pattern)
+		}
+	}
+}
+
+// extractKeyGroupEstimateMetrics estimates the count and memory usage of the key groups from random samples
+// of each database, at most max-distinct-key-groups groups with the most samples are exported per database
+func (e *Exporter) extractKeyGroupEstimateMetrics(ch chan<- prometheus.Metric, c redis.Conn, dbCount int) {
+	keyGroups := e.parseKeyGroups()
+	if len(keyGroups) == 0 && e.keyGroupMatcher == nil {
+		return
+	}
+
+	start := time.Now()
+	for db := 0; db < dbCount; db++ {
+		if _, err := doRedisCmd(c, "SELECT", db); err != nil {
+			log.Errorf("Couldn't select database %d when estimating key groups.", db)
+			continue
+		}
+		dbSize, err := redis.Int64(doRedisCmd(c, "DBSIZE"))
+		if err != nil {
+			log.Errorf("DBSIZE err: %s", err)
+			continue
+		}
+		if dbSize == 0 {
+			continue
+		}
+		samples, err := sampleRandomKeys(c, e.options.KeyCountEstimateSamples, true, keyGroups)
+		if err != nil {
+			log.Errorf("Couldn't sample the keys of database %d, err: %s", db, err)
+			continue
+		}
+		if len(samples) == 0 {
+			continue
+		}
+
+		groups := map[string]*keyGroupMetrics{}
+		for _, s := range samples {
+			if e.keyGroupMatcher != nil {
+				s.keyGroup = e.keyGroupMatcher.keyGroup(s.key)
+			}
+			g, ok := groups[s.keyGroup]
+			if !ok {
+				g = &keyGroupMetrics{keyGroup: s.keyGroup}
+				groups[s.keyGroup] = g
+			}
+			g.count++
+			g.memoryUsage += s.memoryUsage
+		}
+
+		sorted := make([]*keyGroupMetrics, 0, len(groups))
+		for _, g := range groups {
+			sorted = append(sorted, g)
+		}
+		sort.Slice(sorted, func(i, j int) bool {
+			if sorted[i].count == sorted[j].count {
+				return sorted[i].keyGroup < sorted[j].keyGroup
+			}
+			return sorted[i].count > sorted[j].count
+		})
+		if int64(len(sorted)) > e.options.MaxDistinctKeyGroups {
+			overflow := &keyGroupMetrics{keyGroup: "overflow"}
+			for _, g := range sorted[e.options.MaxDistinctKeyGroups:] {
+				overflow.count += g.count
+				overflow.memoryUsage += g.memoryUsage
+			}
+			sorted = append(sorted[:e.options.MaxDistinctKeyGroups], overflow)
+		}
+
+		dbLabel := fmt.Sprintf("db%d", db)
+		e.registerConstMetricGauge(ch, "key_group_estimate_samples", float64(len(samples)), dbLabel)
+		e.registerConstMetricGauge(ch, "number_of_distinct_key_groups", float64(len(groups)), dbLabel)
+		for _, g := range sorted {
+			est, stddev := estimateCount(int(g.count), len(samples), dbSize)
+			e.registerConstMetricGauge(ch, "key_group_count_estimate", est, dbLabel, g.keyGroup)
+			e.registerConstMetricGauge(ch, "key_group_count_estimate_stddev", stddev, dbLabel, g.keyGroup)
+			e.registerConstMetricGauge(ch, "key_group_memory_usage_bytes_estimate", float64(g.memoryUsage)/float64(len(samples))*float64(dbSize), dbLabel, g.keyGroup)
+		}
+	}
+	e.registerConstMetricGauge(ch, "last_key_groups_scrape_duration_milliseconds", float64(time.Since(start).Milliseconds()))
+}
@@ -0,0 +1,120 @@
+package exporter
+
+import (
+	"math"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestGlobToRegexp(t *testing.T) {
+	for _, tst := range []struct {
+		pattern string
+		key     string
+		want    bool
+	}{
+		{"sessions:*", "sessions:abc", true},
+		{"sessions:*", "sessions:", true},
+		{"sessions:*", "session:abc", false},
+		{"sessions:*", "sessions:a/b\nc", true},
+		{"user:?", "user:1", true},
+		{"user:?", "user:12", false},
+		{"user:[ab]*", "user:b1", true},
+		{"user:[^ab]*", "user:b1", false},
+		{"user:[a-c]", "user:c", true},
+		{"a.b+c", "a.b+c", true},
+		{"a.b+c", "aXb+c", false},
+		{`key\*`, "key*", true},
+		{`key\*`, "keys", false},
+	} {
+		re, err := globToRegexp(tst.pattern)
+		if err != nil {
+			t.Fatalf("globToRegexp(%s) err: %s", tst.pattern, err)
+		}
+		if got := re.MatchString(tst.key); got != tst.want {
+			t.Errorf("pattern %s, key %q: want %t, got %t", tst.pattern, tst.key, tst.want, got)
+		}
+	}
+}
+
+func TestEstimateCount(t *testing.T) {
+	est, stddev := estimateCount(250, 1000, 200000000)
+	if est != 50000000 {
+		t.Errorf("want estimate 50000000, got %f", est)
+	}
+	// sqrt(0.25 * 0.75 / 1000) * 200M
+	if want := 200000000 * math.Sqrt(0.25*0.75/1000); math.Abs(stddev-want) > 1e-6 {
+		t.Errorf("want stddev %f, got %f", want, stddev)
+	}
+
+	if est, stddev := estimateCount(0, 0, 100); est != 0 || stddev != 0 {
+		t.Errorf("want 0 without samples, got %f, %f", est, stddev)
+	}
+	// without any or only matching samples the stddev is the upper bound of DBSIZE / samples
+	if est, stddev := estimateCount(0, 10, 100); est != 0 || stddev != 10 {
+		t.Errorf("want 0 and a stddev of 10 if no samples matched, got %f, %f", est, stddev)
+	}
+	if est, stddev := estimateCount(10, 10, 100); est != 100 || stddev != 10 {
+		t.Errorf("want 100 and a stddev of 10 if all samples matched, got %f, %f", est, stddev)
+	}
+}
+
+func TestKeyCountEstimatesWithKeyLabelRegexes(t *testing.T) {
+	opts := Options{KeyCountEstimateSamples: 1000, KeyLabelRegexes: `^queue:(?P<service>[^:]+):`}
+	if _, err := NewRedisExporter("", opts); err != nil {
+		t.Errorf("expected no error without count-keys, got: %s", err)
+	}
+
+	opts.CountKeys = "db0=queue:*"
+	if _, err := NewRedisExporter("", opts); err == nil {
+		t.Errorf("expected an error for key-label-regexes with count-keys estimates")
+	}
+}
+
+func TestKeyCountEstimates(t *testing.T) {
+	if os.Getenv("TEST_REDIS_URI") == "" {
+		t.Skipf("TEST_REDIS_URI not set - skipping")
+	}
+	addr := os.Getenv("TEST_REDIS_URI")
+
+	setupDBKeys(t, addr)
+	defer deleteKeysFromDB(t, addr)
+
+	c, err := redis.DialURL(addr)
+	if err != nil {
+		t.Fatalf("Couldn't connect to %#v: %#v", addr, err)
+	}
+	defer c.Close()
+
+	e, _ := NewRedisExporter(addr, Options{
+		Namespace:               "test",
+		Registry:                prometheus.NewRegistry(),
+		CountKeys:               dbNumStrFull + "=key_exp_*",
+		CheckKeyGroups:          "^(key_exp)_.+$",
+		MaxDistinctKeyGroups:    10,
+		KeyCountEstimateSamples: 500,
+	})
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+
+	body := downloadURL(t, ts.URL+"/metrics")
+	for _, want := range []string{
+		`test_keys_count_estimate{db="db11",key="key_exp_*"}`,
+		`test_keys_count_estimate_stddev{db="db11",key="key_exp_*"}`,
+		`test_keys_count_estimate_samples{db="db11"} 500`,
+		`test_key_group_count_estimate{db="db11",key_group="key_exp"}`,
+		`test_key_group_memory_usage_bytes_estimate{db="db11",key_group="unclassified"}`,
+		`test_key_group_estimate_samples{db="db11"} 500`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("want metrics to include %s, have:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, `test_keys_count{`) {
+		t.Errorf("didn't expect exact key counts, have:\n%s", body)
+	}
+}
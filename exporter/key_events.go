@@ -0,0 +1,242 @@
+package exporter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	keyEventsHealthCheckInterval = 30 * time.Second
+	keyEventsRetryInterval       = 10 * time.Second
+)
+
+// the key events the subscriber listens to and the notify-keyspace-events class that enables them
+var keyEventClasses = []struct {
+	event string
+	class string
+}{
+	{event: "del", class: "g"},
+	{event: "evicted", class: "e"},
+	{event: "expired", class: "x"},
+}
+
+// keyEventPatterns returns the PSUBSCRIBE patterns of the key events enabled by notify-keyspace-events
+func keyEventPatterns(notifyKeyspaceEvents string) []string {
+	if !strings.Contains(notifyKeyspaceEvents, "E") {
+		return nil
+	}
+	var patterns []string
+	for _, ec := range keyEventClasses {
+		// "A" is an alias for all classes
+		if strings.ContainsAny(notifyKeyspaceEvents, ec.class+"A") {
+			patterns = append(patterns, "__keyevent@*__:"+ec.event)
+		}
+	}
+	return patterns
+}
+
+// parseKeyEventChannel extracts the db and the event from a channel like "__keyevent@0__:expired"
+func parseKeyEventChannel(channel string) (db string, event string, ok bool) {
+	if !strings.HasPrefix(channel, "__keyevent@") {
+		return "", "", false
+	}
+	frags := strings.SplitN(strings.TrimPrefix(channel, "__keyevent@"), "__:", 2)
+	if len(frags) != 2 || frags[0] == "" || frags[1] == "" {
+		return "", "", false
+	}
+	return "db" + frags[0], frags[1], true
+}
+
+func parseKeyEventsKeyGroups(s string) ([]*regexp.Regexp, error) {
+	var res []*regexp.Regexp
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %q: %s", p, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+type keyEventKey struct {
+	db       string
+	event    string
+	keyGroup string
+}
+
+// keyEventsSubscriber counts key events in the background, it's started during the first scrape and
+// reconnects on errors. The counters are read during every scrape.
+type keyEventsSubscriber struct {
+	sync.Mutex
+
+	patterns  []string
+	keyGroups []*regexp.Regexp
+	maxGroups int64
+
+	groups    map[string]bool
+	counts    map[keyEventKey]float64
+	connected bool
+
+	done chan struct{}
+}
+
+func newKeyEventsSubscriber(patterns []string, keyGroups []*regexp.Regexp, maxGroups int64) *keyEventsSubscriber {
+	return &keyEventsSubscriber{
+		patterns:  patterns,
+		keyGroups: keyGroups,
+		maxGroups: maxGroups,
+		groups:    map[string]bool{},
+		counts:    map[keyEventKey]float64{},
+		done:      make(chan struct{}),
+	}
+}
+
+// keyGroup returns the group of the key, the group name is the concatenation of the capture groups of the
+// first matching regex. Once maxGroups distinct groups were seen all further groups end up in "overflow".
+func (s *keyEventsSubscriber) keyGroup(key string) string {
+	if len(s.keyGroups) == 0 {
+		return ""
+	}
+	group := "unclassified"
+	for _, re := range s.keyGroups {
+		if m := re.FindStringSubmatch(key); m != nil {
+			group = strings.Join(m[1:], "")
+			break
+		}
+	}
+	if !s.groups[group] {
+		if int64(len(s.groups)) >= s.maxGroups {
+			return "overflow"
+		}
+		s.groups[group] = true
+	}
+	return group
+}
+
+func (s *keyEventsSubscriber) handleMessage(channel string, key string) {
+	db, event, ok := parseKeyEventChannel(channel)
+	if !ok {
+		log.Debugf("Unexpected key event channel: %s", channel)
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	s.counts[keyEventKey{db: db, event: event, keyGroup: s.keyGroup(key)}]++
+}
+
+func (s *keyEventsSubscriber) setConnected(connected bool) {
+	s.Lock()
+	defer s.Unlock()
+	s.connected = connected
+}
+
+func (s *keyEventsSubscriber) stop() {
+	close(s.done)
+}
+
+func (s *keyEventsSubscriber) run(dial func() (redis.Conn, error)) {
+	for {
+		if err := s.subscribe(dial); err != nil {
+			log.Errorf("Key events subscriber err: %s", err)
+		}
+		s.setConnected(false)
+
+		select {
+		case <-s.done:
+			return
+		case <-time.After(keyEventsRetryInterval):
+		}
+	}
+}
+
+func (s *keyEventsSubscriber) subscribe(dial func() (redis.Conn, error)) error {
+	c, err := dial()
+	if err != nil {
+		return err
+	}
+	psc := redis.PubSubConn{Conn: c}
+	defer psc.Close()
+
+	if err := psc.PSubscribe(redis.Args{}.AddFlat(s.patterns)...); err != nil {
+		return err
+	}
+
+	// pings keep the connection healthy, unsubscribing ends the receive loop below when the subscriber is stopped
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		ticker := time.NewTicker(keyEventsHealthCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := psc.Ping(""); err != nil {
+					return
+				}
+			case <-s.done:
+				psc.PUnsubscribe()
+				return
+			case <-finished:
+				return
+			}
+		}
+	}()
+
+	for {
+		switch v := psc.ReceiveWithTimeout(2 * keyEventsHealthCheckInterval).(type) {
+		case redis.Message:
+			s.handleMessage(v.Channel, string(v.Data))
+		case redis.Subscription:
+			if v.Count == 0 {
+				return nil
+			}
+			s.setConnected(true)
+		case error:
+			return v
+		}
+	}
+}
+
+func (e *Exporter) extractKeyEventsMetrics(ch chan<- prometheus.Metric, notifyKeyspaceEvents string) {
+	patterns := keyEventPatterns(notifyKeyspaceEvents)
+	if e.keyEvents != nil && strings.Join(e.keyEvents.patterns, ",") != strings.Join(patterns, ",") {
+		// notify-keyspace-events changed
+		e.keyEvents.stop()
+		e.keyEvents = nil
+	}
+
+	if len(patterns) == 0 {
+		log.Debugf("Key events not enabled, notify-keyspace-events: %q", notifyKeyspaceEvents)
+		e.registerConstMetricGauge(ch, "key_events_subscribed", 0)
+		return
+	}
+
+	if e.keyEvents == nil {
+		e.keyEvents = newKeyEventsSubscriber(patterns, e.keyEventsKeyGroups, e.options.MaxDistinctKeyGroups)
+		go e.keyEvents.run(e.connectToRedis)
+	}
+
+	e.keyEvents.Lock()
+	defer e.keyEvents.Unlock()
+
+	connected := 0.0
+	if e.keyEvents.connected {
+		connected = 1
+	}
+	e.registerConstMetricGauge(ch, "key_events_subscribed", connected)
+	for k, cnt := range e.keyEvents.counts {
+		e.registerConstMetric(ch, "key_events_total", cnt, prometheus.CounterValue, k.db, k.event, k.keyGroup)
+	}
+}
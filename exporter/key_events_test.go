@@ -0,0 +1,153 @@
+package exporter
+
+import (
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestKeyEventPatterns(t *testing.T) {
+	for _, tst := range []struct {
+		in   string
+		want string
+	}{
+		{in: "", want: ""},
+		{in: "Kx", want: ""},
+		{in: "Ex", want: "__keyevent@*__:expired"},
+		{in: "gxE", want: "__keyevent@*__:del,__keyevent@*__:expired"},
+		{in: "AKE", want: "__keyevent@*__:del,__keyevent@*__:evicted,__keyevent@*__:expired"},
+	} {
+		if got := strings.Join(keyEventPatterns(tst.in), ","); got != tst.want {
+			t.Errorf("keyEventPatterns(%q) - want: %q, got: %q", tst.in, tst.want, got)
+		}
+	}
+}
+
+func TestParseKeyEventChannel(t *testing.T) {
+	for _, tst := range []struct {
+		in        string
+		wantDB    string
+		wantEvent string
+		wantOk    bool
+	}{
+		{in: "__keyevent@0__:expired", wantDB: "db0", wantEvent: "expired", wantOk: true},
+		{in: "__keyevent@11__:del", wantDB: "db11", wantEvent: "del", wantOk: true},
+		{in: "__keyspace@0__:mykey", wantOk: false},
+		{in: "__keyevent@__:del", wantOk: false},
+	} {
+		db, event, ok := parseKeyEventChannel(tst.in)
+		if ok != tst.wantOk || db != tst.wantDB || event != tst.wantEvent {
+			t.Errorf("parseKeyEventChannel(%q) - unexpected result: %s %s %t", tst.in, db, event, ok)
+		}
+	}
+}
+
+func TestKeyEventsSubscriberCounts(t *testing.T) {
+	groups, err := parseKeyEventsKeyGroups("^(session):, ^(user):(admin)?")
+	if err != nil {
+		t.Fatalf("parseKeyEventsKeyGroups() err: %s", err)
+	}
+	if _, err := parseKeyEventsKeyGroups("^(unclosed"); err == nil {
+		t.Errorf("expected an error for an invalid regex")
+	}
+
+	s := newKeyEventsSubscriber([]string{"__keyevent@*__:expired"}, groups, 3)
+	for _, msg := range []struct{ channel, key string }{
+		{"__keyevent@0__:expired", "session:1"},
+		{"__keyevent@0__:expired", "session:2"},
+		{"__keyevent@1__:del", "user:admin:1"},
+		{"__keyevent@0__:evicted", "user:42"},
+		{"__keyevent@0__:evicted", "something"},
+		{"invalid", "session:3"},
+	} {
+		s.handleMessage(msg.channel, msg.key)
+	}
+
+	want := map[keyEventKey]float64{
+		{db: "db0", event: "expired", keyGroup: "session"}:  2,
+		{db: "db1", event: "del", keyGroup: "useradmin"}:    1,
+		{db: "db0", event: "evicted", keyGroup: "user"}:     1,
+		{db: "db0", event: "evicted", keyGroup: "overflow"}: 1,
+	}
+	if len(s.counts) != len(want) {
+		t.Fatalf("want: %#v, got: %#v", want, s.counts)
+	}
+	for k, v := range want {
+		if s.counts[k] != v {
+			t.Errorf("unexpected count for %#v, want: %f, got: %f", k, v, s.counts[k])
+		}
+	}
+}
+
+func TestKeyEventsMetrics(t *testing.T) {
+	if os.Getenv("TEST_REDIS_URI") == "" {
+		t.Skipf("TEST_REDIS_URI not set - skipping")
+	}
+	addr := os.Getenv("TEST_REDIS_URI")
+
+	c, err := redis.DialURL(addr)
+	if err != nil {
+		t.Fatalf("Couldn't connect to %#v: %#v", addr, err)
+	}
+	defer c.Close()
+
+	notify, err := redis.Strings(doRedisCmd(c, "CONFIG", "GET", "notify-keyspace-events"))
+	if err != nil || len(notify) != 2 {
+		t.Fatalf("Couldn't get notify-keyspace-events: %s", err)
+	}
+	if _, err := doRedisCmd(c, "CONFIG", "SET", "notify-keyspace-events", "Eg"); err != nil {
+		t.Fatalf("Couldn't set notify-keyspace-events: %s", err)
+	}
+	defer doRedisCmd(c, "CONFIG", "SET", "notify-keyspace-events", notify[1])
+
+	e, _ := NewRedisExporter(addr, Options{
+		Namespace:          "test",
+		Registry:           prometheus.NewRegistry(),
+		ExportKeyEvents:    true,
+		KeyEventsKeyGroups: "^(key_events)_",
+	})
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+
+	// the first scrape starts the subscriber
+	downloadURL(t, ts.URL+"/metrics")
+	defer func() {
+		e.Lock()
+		e.keyEvents.stop()
+		e.Unlock()
+	}()
+
+	for i := 0; i < 50; i++ {
+		e.keyEvents.Lock()
+		connected := e.keyEvents.connected
+		e.keyEvents.Unlock()
+		if connected {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if _, err := doRedisCmd(c, "SELECT", dbNumStr); err != nil {
+		t.Fatalf("Couldn't select database %s: %s", dbNumStr, err)
+	}
+	for _, k := range []string{"key_events_1", "key_events_2"} {
+		doRedisCmd(c, "SET", k, "val")
+		doRedisCmd(c, "DEL", k)
+	}
+	time.Sleep(500 * time.Millisecond)
+
+	body := downloadURL(t, ts.URL+"/metrics")
+	for _, want := range []string{
+		`test_key_events_subscribed 1`,
+		`test_key_events_total{db="db11",event="del",key_group="key_events"} 2`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("want metrics to include %s, have:\n%s", want, body)
+		}
+	}
+}
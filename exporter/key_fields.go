@@ -0,0 +1,105 @@
+package exporter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+type dbKeyFields struct {
+	dbKeyPair
+	fields []string
+}
+
+// parseKeyFieldsArg parses the check-key-fields argument, it uses the check-keys format and every key
+// (or pattern) is followed by a "#" and the ";" separated list of hash fields, e.g. db0=stats:*#calls;errors
+func parseKeyFieldsArg(arg string) ([]dbKeyFields, error) {
+	keys, err := parseKeyArg(arg)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]dbKeyFields, 0, len(keys))
+	for _, k := range keys {
+		idx := strings.LastIndex(k.key, "#")
+		if idx <= 0 {
+			return nil, fmt.Errorf("missing fields of key: %s", k.key)
+		}
+
+		var fields []string
+		for _, f := range strings.Split(k.key[idx+1:], ";") {
+			if f = strings.TrimSpace(f); f != "" {
+				fields = append(fields, f)
+			}
+		}
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("missing fields of key: %s", k.key)
+		}
+		res = append(res, dbKeyFields{dbKeyPair: dbKeyPair{db: k.db, key: k.key[:idx]}, fields: fields})
+	}
+	return res, nil
+}
+
+func (e *Exporter) extractKeyFieldsMetrics(ch chan<- prometheus.Metric, c redis.Conn) {
+	keyFields, err := parseKeyFieldsArg(e.options.CheckKeyFields)
+	if err != nil {
+		log.Errorf("Couldn't parse check-key-fields: %#v", err)
+		return
+	}
+
+	for _, kf := range keyFields {
+		keys, err := getKeysFromPatterns(c, []dbKeyPair{kf.dbKeyPair}, e.options.CheckKeysBatchSize)
+		if err != nil {
+			log.Errorf("Error expanding key pattern %s: %s", kf.key, err)
+			continue
+		}
+
+		db := kf.db
+		if e.options.IsCluster {
+			// Cluster mode only has one db
+			db = "0"
+		} else if _, err := doRedisCmd(c, "SELECT", db); err != nil {
+			log.Errorf("Couldn't select database %#v when getting key fields.", db)
+			continue
+		}
+		dbLabel := "db" + db
+
+		args := make([]interface{}, 0, len(kf.fields)+1)
+		args = append(args, "")
+		for _, f := range kf.fields {
+			args = append(args, f)
+		}
+
+		for _, k := range keys {
+			args[0] = k.key
+			values, err := redis.Values(doRedisCmd(c, "HMGET", args...))
+			if err != nil {
+				log.Debugf("HMGET %s err: %s", k.key, err)
+				continue
+			}
+			e.registerKeyFieldValues(ch, dbLabel, k.key, kf.fields, values)
+		}
+	}
+}
+
+func (e *Exporter) registerKeyFieldValues(ch chan<- prometheus.Metric, dbLabel string, key string, fields []string, values []interface{}) {
+	for i, v := range values {
+		if i >= len(fields) {
+			break
+		}
+		strVal, err := redis.String(v, nil)
+		if err != nil {
+			// field doesn't exist
+			continue
+		}
+		if val, err := strconv.ParseFloat(strVal, 64); err == nil {
+			e.registerConstMetricGauge(ch, "key_field_value", val, dbLabel, key, fields[i])
+		} else {
+			e.registerConstMetricGauge(ch, "key_field_value_as_string", 1.0, dbLabel, key, fields[i], strVal)
+		}
+	}
+}
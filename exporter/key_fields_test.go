@@ -0,0 +1,90 @@
+package exporter
+
+import (
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestParseKeyFieldsArg(t *testing.T) {
+	got, err := parseKeyFieldsArg("db0=stats:*#calls;errors,db1=user#1#visits,counters#hits; ;")
+	if err != nil {
+		t.Fatalf("parseKeyFieldsArg() err: %s", err)
+	}
+	want := []dbKeyFields{
+		{dbKeyPair: dbKeyPair{db: "0", key: "stats:*"}, fields: []string{"calls", "errors"}},
+		{dbKeyPair: dbKeyPair{db: "1", key: "user#1"}, fields: []string{"visits"}},
+		{dbKeyPair: dbKeyPair{db: "0", key: "counters"}, fields: []string{"hits"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want: %#v, got: %#v", want, got)
+	}
+
+	for _, arg := range []string{"db0=stats", "db0=stats#", "db0=#calls", "db0=stats#;", "dbx=stats#calls"} {
+		if _, err := parseKeyFieldsArg(arg); err == nil {
+			t.Errorf("expected error for %s", arg)
+		}
+	}
+}
+
+func TestRegisterKeyFieldValues(t *testing.T) {
+	e, _ := NewRedisExporter("", Options{Namespace: "test"})
+
+	got := collectGauges(func(ch chan<- prometheus.Metric) {
+		e.registerKeyFieldValues(ch, "db0", "stats:api", []string{"calls", "missing", "state"},
+			[]interface{}{[]byte("42"), nil, []byte("degraded")})
+	})
+	want := map[string]float64{
+		`key_field_value{db="db0",field="calls",key="stats:api"}`:                          42,
+		`key_field_value_as_string{db="db0",field="state",key="stats:api",val="degraded"}`: 1,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want: %#v, got: %#v", want, got)
+	}
+}
+
+func TestKeyFieldsMetrics(t *testing.T) {
+	if os.Getenv("TEST_REDIS_URI") == "" {
+		t.Skipf("TEST_REDIS_URI not set - skipping")
+	}
+	addr := os.Getenv("TEST_REDIS_URI")
+
+	c, err := redis.DialURL(addr)
+	if err != nil {
+		t.Fatalf("Couldn't connect to %#v: %#v", addr, err)
+	}
+	defer c.Close()
+
+	if _, err := doRedisCmd(c, "SELECT", dbNumStr); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"field_stats:api", "field_stats:web"} {
+		if _, err := doRedisCmd(c, "HSET", key, "calls", 10, "state", "ok"); err != nil {
+			t.Fatal(err)
+		}
+		defer doRedisCmd(c, "DEL", key)
+	}
+
+	e, _ := NewRedisExporter(addr, Options{Namespace: "test", Registry: prometheus.NewRegistry(), CheckKeyFields: dbNumStrFull + "=field_stats:*#calls;state;missing", CheckKeysBatchSize: 1000})
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+
+	body := downloadURL(t, ts.URL+"/metrics")
+	for _, want := range []string{
+		`test_key_field_value{db="db11",field="calls",key="field_stats:api"} 10`,
+		`test_key_field_value{db="db11",field="calls",key="field_stats:web"} 10`,
+		`test_key_field_value_as_string{db="db11",field="state",key="field_stats:web",val="ok"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("want metrics to include %s, have:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, `field="missing"`) {
+		t.Errorf("didn't expect metrics for missing fields, have:\n%s", body)
+	}
+}
@@ -0,0 +1,188 @@
+package exporter
+
+import (
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// keyGroupMatcher groups keys without the lua patterns of check-key-groups. A key
+// belongs to the first glob pattern of key-group-globs that matches it, otherwise the group is derived
+// from its prefix of key-group-prefix-depth parts separated by key-group-prefix-delimiter.
+type keyGroupMatcher struct {
+	globs       []string
+	regexes     []*regexp.Regexp
+	luaPatterns []string
+	delimiter   string
+	depth       int
+}
+
+// parseKeyGroupMatcher returns nil if neither globs nor a delimiter are set
+func parseKeyGroupMatcher(globs string, delimiter string, depth int64) (*keyGroupMatcher, error) {
+	m := &keyGroupMatcher{delimiter: delimiter, depth: int(depth)}
+	for _, g := range strings.Split(globs, ",") {
+		if g = strings.TrimSpace(g); g == "" {
+			continue
+		}
+		re, err := globToRegexp(g)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %s", g, err)
+		}
+		m.globs = append(m.globs, g)
+		m.regexes = append(m.regexes, re)
+		m.luaPatterns = append(m.luaPatterns, globToLuaPattern(g))
+	}
+	if len(m.globs) == 0 && m.delimiter == "" {
+		return nil, nil
+	}
+	if m.delimiter != "" && m.depth < 1 {
+		return nil, fmt.Errorf("prefix depth must be at least 1, got %d", depth)
+	}
+	return m, nil
+}
+
+// keyGroup returns the matching glob or the prefix of the key followed by the delimiter and "*",
+// e.g. "user:*" for "user:1:profile" with delimiter ":" and depth 1. Keys without delimiter are
+// "unclassified" so key names never become key groups.
+func (m *keyGroupMatcher) keyGroup(key string) string {
+	for i, re := range m.regexes {
+		if re.MatchString(key) {
+			return m.globs[i]
+		}
+	}
+	if m.delimiter == "" {
+		return "unclassified"
+	}
+	parts := strings.SplitN(key, m.delimiter, m.depth+1)
+	if len(parts) == 1 {
+		return "unclassified"
+	}
+	// keys with fewer parts get the longest prefix they have, the last part is never a group
+	depth := m.depth
+	if len(parts) <= depth {
+		depth = len(parts) - 1
+	}
+	return strings.Join(parts[:depth], m.delimiter) + m.delimiter + "*"
+}
+
+// luaArgs returns the arguments of keyGroupMatcherScript after the ones of keyGroupScanArgs
+func (m *keyGroupMatcher) luaArgs() []interface{} {
+	args := []interface{}{m.delimiter, m.depth}
+	for i, g := range m.globs {
+		args = append(args, g, m.luaPatterns[i])
+	}
+	return args
+}
+
+// keyGroupMatcherScript is keyGroupMatcher.keyGroup in lua so the key names don't have to be sent to the
+// exporter, ARGV[4] is the delimiter, ARGV[5] the depth and the globs follow as pairs of glob and lua pattern
+var keyGroupMatcherScript = redis.NewScript(0, `
+local delimiter = ARGV[4]
+local depth = tonumber(ARGV[5])
+local function key_group(key)
+  for i=6,#ARGV,2 do
+    if string.find(key, ARGV[i+1]) then
+      return ARGV[i]
+    end
+  end
+  if delimiter == "" then
+    return "unclassified"
+  end
+  local last = nil
+  local pos = 1
+  for i=1,depth do
+    local s = string.find(key, delimiter, pos, true)
+    if s == nil then
+      break
+    end
+    last = s
+    pos = s + #delimiter
+  end
+  if last == nil then
+    return "unclassified"
+  end
+  return string.sub(key, 1, last - 1) .. delimiter .. "*"
+end
+`+keyGroupsAggregationLua)
+
+// gatherKeyGroupMetricsWithMatcher is gatherKeyGroupMetrics with the key groups of m. At most maxGroups key
+// groups are tracked, the keys of groups that are found after that are added to the overflow group right
+// away and only the hashes of their names are kept to count the distinct key groups.
+func gatherKeyGroupMetricsWithMatcher(c redis.Conn, batchSize int64, breakdown bool, m *keyGroupMatcher, maxGroups int64) (map[string]*keyGroupMetrics, *overflowedKeyGroupMetrics, error) {
+	allGroups := make(map[string]*keyGroupMetrics)
+	overflow := keyGroupMetrics{keyGroup: "overflow"}
+	overflowedGroups := map[uint64]bool{}
+
+	err := scanKeyGroups(c, keyGroupMatcherScript, append(keyGroupScanArgs(batchSize, breakdown), m.luaArgs()...), func(metrics *keyGroupMetrics) {
+		if currentMetrics, ok := allGroups[metrics.keyGroup]; ok {
+			currentMetrics.add(metrics)
+		} else if int64(len(allGroups)) < maxGroups {
+			allGroups[metrics.keyGroup] = metrics
+		} else {
+			h := fnv.New64a()
+			h.Write([]byte(metrics.keyGroup))
+			overflowedGroups[h.Sum64()] = true
+			overflow.add(metrics)
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(overflowedGroups) == 0 {
+		return allGroups, nil, nil
+	}
+	res := &overflowedKeyGroupMetrics{
+		overflowKeyGroupAggregate: overflow,
+		keyGroupsCount:            int64(len(allGroups) + len(overflowedGroups)),
+	}
+	for _, g := range allGroups {
+		res.topMemoryUsageKeyGroups = append(res.topMemoryUsageKeyGroups, g)
+	}
+	return allGroups, res, nil
+}
+
+// globToLuaPattern converts a glob-style pattern as used by SCAN's MATCH option into an anchored lua pattern
+func globToLuaPattern(pattern string) string {
+	var sb strings.Builder
+	sb.WriteString("^")
+	inClass := false
+	for i := 0; i < len(pattern); i++ {
+		ch := pattern[i]
+		switch {
+		case ch == '\\' && i+1 < len(pattern):
+			i++
+			sb.WriteString(quoteLuaPatternChar(pattern[i]))
+		case inClass:
+			if ch == ']' {
+				inClass = false
+			}
+			if ch == '%' {
+				sb.WriteString("%%")
+			} else {
+				sb.WriteByte(ch)
+			}
+		case ch == '*':
+			sb.WriteString(".*")
+		case ch == '?':
+			sb.WriteString(".")
+		case ch == '[':
+			inClass = true
+			sb.WriteByte(ch)
+		default:
+			sb.WriteString(quoteLuaPatternChar(ch))
+		}
+	}
+	sb.WriteString("$")
+	return sb.String()
+}
+
+func quoteLuaPatternChar(ch byte) string {
+	if strings.IndexByte("^$()%.[]*+-?", ch) >= 0 {
+		return "%" + string(ch)
+	}
+	return string(ch)
+}
@@ -0,0 +1,136 @@
+package exporter
+
+import (
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestKeyGroupMatcher(t *testing.T) {
+	m, err := parseKeyGroupMatcher("sessions:*, cache:user:* ,", ":", 1)
+	if err != nil {
+		t.Fatalf("parseKeyGroupMatcher() err: %s", err)
+	}
+	for key, want := range map[string]string{
+		"sessions:abc":      "sessions:*",
+		"cache:user:1":      "cache:user:*",
+		"cache:item:1":      "cache:*",
+		"user:1:profile":    "user:*",
+		"user:":             "user:*",
+		"standalone":        "unclassified",
+		"sessionsabc:other": "sessionsabc:*",
+	} {
+		if got := m.keyGroup(key); got != want {
+			t.Errorf("key %s: want %s, got %s", key, want, got)
+		}
+	}
+
+	m, _ = parseKeyGroupMatcher("", "/", 2)
+	if got := m.keyGroup("a/b/c/d"); got != "a/b/*" {
+		t.Errorf("want a/b/*, got %s", got)
+	}
+	if got := m.keyGroup("a/b"); got != "a/*" {
+		t.Errorf("want a/*, got %s", got)
+	}
+
+	m, _ = parseKeyGroupMatcher("sessions:*", "", 1)
+	if got := m.keyGroup("user:1"); got != "unclassified" {
+		t.Errorf("want unclassified, got %s", got)
+	}
+
+	if m, err := parseKeyGroupMatcher(" ", "", 1); m != nil || err != nil {
+		t.Errorf("want no matcher without globs and delimiter, got %#v, %s", m, err)
+	}
+	if _, err := parseKeyGroupMatcher("", ":", 0); err == nil {
+		t.Errorf("expected error for prefix depth 0")
+	}
+	if _, err := NewRedisExporter("", Options{CheckKeyGroups: "^(.*)$", KeyGroupGlobs: "sessions:*"}); err == nil {
+		t.Errorf("expected error for check-key-groups with key-group-globs")
+	}
+}
+
+func TestGlobToLuaPattern(t *testing.T) {
+	for glob, want := range map[string]string{
+		"sessions:*":     "^sessions:.*$",
+		"user:?":         "^user:.$",
+		"user:[^a-c]*":   "^user:[^a-c].*$",
+		"a.b+c-d%(e)":    "^a%.b%+c%-d%%%(e%)$",
+		`key\*`:          "^key%*$",
+		`key\a`:          "^keya$",
+		"[%]":            "^[%%]$",
+		"cache:$user^id": "^cache:%$user%^id$",
+	} {
+		if got := globToLuaPattern(glob); got != want {
+			t.Errorf("glob %s: want %s, got %s", glob, want, got)
+		}
+	}
+}
+
+func TestKeyGroupMatcherMetrics(t *testing.T) {
+	if os.Getenv("TEST_REDIS_URI") == "" {
+		t.Skipf("TEST_REDIS_URI not set - skipping")
+	}
+	addr := os.Getenv("TEST_REDIS_URI")
+
+	c, err := redis.DialURL(addr)
+	if err != nil {
+		t.Fatalf("Couldn't connect to %#v: %#v", addr, err)
+	}
+	defer c.Close()
+
+	if _, err := doRedisCmd(c, "SELECT", dbNumStr); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"matcher_user:1:profile", "matcher_user:2:profile", "matcher_sessions:1"} {
+		if _, err := doRedisCmd(c, "SET", key, "abc"); err != nil {
+			t.Fatal(err)
+		}
+		defer doRedisCmd(c, "DEL", key)
+	}
+
+	e, _ := NewRedisExporter(addr, Options{
+		Namespace:               "test",
+		Registry:                prometheus.NewRegistry(),
+		KeyGroupGlobs:           "matcher_sessions:*",
+		KeyGroupPrefixDelimiter: ":",
+		KeyGroupPrefixDepth:     1,
+		CheckKeysBatchSize:      1000,
+		MaxDistinctKeyGroups:    100,
+	})
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+
+	body := downloadURL(t, ts.URL+"/metrics")
+	for _, want := range []string{
+		`test_key_group_count{db="db11",key_group="matcher_user:*"} 2`,
+		`test_key_group_count{db="db11",key_group="matcher_sessions:*"} 1`,
+		`test_key_group_memory_usage_bytes{db="db11",key_group="matcher_user:*"}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("want metrics to include %s, have:\n%s", want, body)
+		}
+	}
+
+	// the key groups found after the first one go to overflow right away
+	e, _ = NewRedisExporter(addr, Options{
+		Namespace:               "test",
+		Registry:                prometheus.NewRegistry(),
+		KeyGroupPrefixDelimiter: ":",
+		CheckKeysBatchSize:      1000,
+		MaxDistinctKeyGroups:    1,
+	})
+	ts2 := httptest.NewServer(e)
+	defer ts2.Close()
+
+	body = downloadURL(t, ts2.URL+"/metrics")
+	if !strings.Contains(body, `test_key_group_count{db="db11",key_group="overflow"}`) {
+		t.Errorf("want overflow key group, have:\n%s", body)
+	}
+	if strings.Count(body, `test_key_group_count{db="db11"`) != 2 {
+		t.Errorf("want two key groups, have:\n%s", body)
+	}
+}
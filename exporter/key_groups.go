@@ -16,6 +16,39 @@ type keyGroupMetrics struct {
 	keyGroup    string
 	count       int64
 	memoryUsage int64
+	// the breakdown is only gathered with key-groups-breakdown
+	withTTLCount int64
+	types        map[string]*keyGroupTypeMetrics
+	encodings    map[string]int64
+}
+
+type keyGroupTypeMetrics struct {
+	count       int64
+	memoryUsage int64
+}
+
+// add merges the metrics of other into m
+func (m *keyGroupMetrics) add(other *keyGroupMetrics) {
+	m.count += other.count
+	m.memoryUsage += other.memoryUsage
+	m.withTTLCount += other.withTTLCount
+	for t, v := range other.types {
+		if m.types == nil {
+			m.types = map[string]*keyGroupTypeMetrics{}
+		}
+		if cur, ok := m.types[t]; ok {
+			cur.count += v.count
+			cur.memoryUsage += v.memoryUsage
+		} else {
+			m.types[t] = &keyGroupTypeMetrics{count: v.count, memoryUsage: v.memoryUsage}
+		}
+	}
+	for enc, count := range other.encodings {
+		if m.encodings == nil {
+			m.encodings = map[string]int64{}
+		}
+		m.encodings[enc] += count
+	}
 }
 
 type overflowedKeyGroupMetrics struct {
@@ -52,6 +85,18 @@ func (e *Exporter) extractKeyGroupMetrics(ch chan<- prometheus.Metric, c redis.C
 				dbLabel,
 				metrics.keyGroup,
 			)
+			if !e.options.KeyGroupsBreakdown {
+				return
+			}
+			e.registerConstMetricGauge(ch, "key_group_with_ttl_count", float64(metrics.withTTLCount), dbLabel, metrics.keyGroup)
+			e.registerConstMetricGauge(ch, "key_group_without_ttl_count", float64(metrics.count-metrics.withTTLCount), dbLabel, metrics.keyGroup)
+			for t, v := range metrics.types {
+				e.registerConstMetricGauge(ch, "key_group_type_count", float64(v.count), dbLabel, metrics.keyGroup, t)
+				e.registerConstMetricGauge(ch, "key_group_type_memory_usage_bytes", float64(v.memoryUsage), dbLabel, metrics.keyGroup, t)
+			}
+			for enc, count := range metrics.encodings {
+				e.registerConstMetricGauge(ch, "key_group_encoding_count", float64(count), dbLabel, metrics.keyGroup, enc)
+			}
 		}
 		if allDbKeyGroupMetrics.overflowedMetrics[db] != nil {
 			overflowedMetrics := allDbKeyGroupMetrics.overflowedMetrics[db]
@@ -79,27 +124,8 @@ func (e *Exporter) gatherKeyGroupsMetricsForAllDatabases(c redis.Conn, dbCount i
 	defer func() {
 		allMetrics.duration = time.Since(start)
 	}()
-	if strings.TrimSpace(e.options.CheckKeyGroups) == "" {
-		return allMetrics
-	}
-	keyGroups, err := csv.NewReader(
-		strings.NewReader(e.options.CheckKeyGroups),
-	).Read()
-	if err != nil {
-		log.Errorf("Failed to parse key groups as csv: %s", err)
-		return allMetrics
-	}
-	for i, v := range keyGroups {
-		keyGroups[i] = strings.TrimSpace(v)
-	}
-
-	keyGroupsNoEmptyStrings := make([]string, 0)
-	for _, v := range keyGroups {
-		if len(v) > 0 {
-			keyGroupsNoEmptyStrings = append(keyGroupsNoEmptyStrings, v)
-		}
-	}
-	if len(keyGroupsNoEmptyStrings) == 0 {
+	keyGroupsNoEmptyStrings := e.parseKeyGroups()
+	if len(keyGroupsNoEmptyStrings) == 0 && e.keyGroupMatcher == nil {
 		return allMetrics
 	}
 	for db := 0; db < dbCount; db++ {
@@ -107,7 +133,17 @@ func (e *Exporter) gatherKeyGroupsMetricsForAllDatabases(c redis.Conn, dbCount i
 			log.Errorf("Couldn't select database %d when getting key info.", db)
 			continue
 		}
-		allGroups, err := gatherKeyGroupMetrics(c, e.options.CheckKeysBatchSize, keyGroupsNoEmptyStrings)
+		if e.keyGroupMatcher != nil {
+			allGroups, overflowed, err := gatherKeyGroupMetricsWithMatcher(c, e.options.CheckKeysBatchSize, e.options.KeyGroupsBreakdown, e.keyGroupMatcher, e.options.MaxDistinctKeyGroups)
+			if err != nil {
+				log.Error(err)
+				continue
+			}
+			allMetrics.metrics[db] = allGroups
+			allMetrics.overflowedMetrics[db] = overflowed
+			continue
+		}
+		allGroups, err := gatherKeyGroupMetrics(c, e.options.CheckKeysBatchSize, e.options.KeyGroupsBreakdown, keyGroupsNoEmptyStrings)
 		if err != nil {
 			log.Error(err)
 			continue
@@ -127,110 +163,213 @@ func (e *Exporter) gatherKeyGroupsMetricsForAllDatabases(c redis.Conn, dbCount i
 				}
 				return metricsSlice[i].memoryUsage > metricsSlice[j].memoryUsage
 			})
-			var overflowedCount, overflowedMemoryUsage int64
+			overflowed := keyGroupMetrics{keyGroup: "overflow"}
 			for _, v := range metricsSlice[e.options.MaxDistinctKeyGroups:] {
-				overflowedCount += v.count
-				overflowedMemoryUsage += v.memoryUsage
+				overflowed.add(v)
 			}
 			allMetrics.overflowedMetrics[db] = &overflowedKeyGroupMetrics{
-				topMemoryUsageKeyGroups: metricsSlice[:e.options.MaxDistinctKeyGroups],
-				overflowKeyGroupAggregate: keyGroupMetrics{
-					keyGroup:    "overflow",
-					count:       overflowedCount,
-					memoryUsage: overflowedMemoryUsage,
-				},
-				keyGroupsCount: int64(len(allGroups)),
+				topMemoryUsageKeyGroups:   metricsSlice[:e.options.MaxDistinctKeyGroups],
+				overflowKeyGroupAggregate: overflowed,
+				keyGroupsCount:            int64(len(allGroups)),
 			}
 		}
 	}
 	return allMetrics
 }
 
-func gatherKeyGroupMetrics(c redis.Conn, batchSize int64, keyGroups []string) (map[string]*keyGroupMetrics, error) {
-	allGroups := make(map[string]*keyGroupMetrics)
-	keysAndArgs := []interface{}{0, batchSize}
-	for _, keyGroup := range keyGroups {
-		keysAndArgs = append(keysAndArgs, keyGroup)
+// parseKeyGroups returns the lua patterns of the check-key-groups option
+func (e *Exporter) parseKeyGroups() []string {
+	if strings.TrimSpace(e.options.CheckKeyGroups) == "" {
+		return nil
 	}
+	keyGroups, err := csv.NewReader(
+		strings.NewReader(e.options.CheckKeyGroups),
+	).Read()
+	if err != nil {
+		log.Errorf("Failed to parse key groups as csv: %s", err)
+		return nil
+	}
+
+	keyGroupsNoEmptyStrings := make([]string, 0)
+	for _, v := range keyGroups {
+		if v = strings.TrimSpace(v); len(v) > 0 {
+			keyGroupsNoEmptyStrings = append(keyGroupsNoEmptyStrings, v)
+		}
+	}
+	return keyGroupsNoEmptyStrings
+}
 
-	script := redis.NewScript(
-		0,
-		`
+// keyGroupLuaFunctions are shared by the lua scripts that group keys, the patterns are passed
+// as ARGV starting at index "first" and the captures of the first matching pattern form the key group.
+const keyGroupLuaFunctions = `
+local function check_key_group_patterns(first)
+  local status = false
+  local err = nil
+  for i=first,#ARGV do
+    status, err = pcall(string.find, " ", ARGV[i])
+    if not status then
+      error(err .. ARGV[i])
+    end
+  end
+end
+local function get_key_group(key, first)
+  local key_match_result = {}
+  for i=first,#ARGV do
+    key_match_result = {string.find(key, ARGV[i])}
+    if key_match_result[1] ~= nil then
+      return table.concat({unpack(key_match_result, 3,  #key_match_result)},  "")
+    end
+  end
+  return "unclassified"
+end
+`
+
+// parseKeyGroupMetrics parses the metrics of a key group returned by the key group lua script,
+// {group, count, memory usage} optionally followed by {keys with TTL, {type, count, memory usage, ...}, {encoding, count, ...}}
+func parseKeyGroupMetrics(reply interface{}) (*keyGroupMetrics, error) {
+	arr, err := redis.Values(reply, nil)
+	if err != nil || (len(arr) != 3 && len(arr) != 6) {
+		return nil, fmt.Errorf("invalid key group metrics: %v", reply)
+	}
+	m := &keyGroupMetrics{}
+	m.keyGroup, _ = redis.String(arr[0], nil)
+	m.count, _ = redis.Int64(arr[1], nil)
+	m.memoryUsage, _ = redis.Int64(arr[2], nil)
+	if len(arr) == 3 {
+		return m, nil
+	}
+
+	m.withTTLCount, _ = redis.Int64(arr[3], nil)
+	m.types = map[string]*keyGroupTypeMetrics{}
+	types, _ := redis.Values(arr[4], nil)
+	for i := 0; i+2 < len(types); i += 3 {
+		t, _ := redis.String(types[i], nil)
+		v := &keyGroupTypeMetrics{}
+		v.count, _ = redis.Int64(types[i+1], nil)
+		v.memoryUsage, _ = redis.Int64(types[i+2], nil)
+		m.types[t] = v
+	}
+	m.encodings = map[string]int64{}
+	encodings, _ := redis.Values(arr[5], nil)
+	for i := 0; i+1 < len(encodings); i += 2 {
+		enc, _ := redis.String(encodings[i], nil)
+		m.encodings[enc], _ = redis.Int64(encodings[i+1], nil)
+	}
+	return m, nil
+}
+
+// keyGroupsAggregationLua aggregates the keys of a SCAN batch (cursor ARGV[1], count ARGV[2]) by the key group
+// returned by the lua function key_group(key) that has to be defined in front of it. With ARGV[3] set to "1"
+// the keys are also broken down by type, TTL presence and encoding, see parseKeyGroupMetrics for the result.
+const keyGroupsAggregationLua = `
 local result = {}
 local batch = redis.call("SCAN", ARGV[1], "COUNT", ARGV[2])
+local breakdown = ARGV[3] == "1"
 local groups = {}
 local usage = 0
-local group_index = 0
 local group = nil
 local value = {}
-local key_match_result = {}
-local status = false
-local err = nil
-for i=3,#ARGV do
-  status, err = pcall(string.find, " ", ARGV[i])
-  if not status then
-    error(err .. ARGV[i])
-  end
-end
 for i,key in ipairs(batch[2]) do
-  usage = redis.call("MEMORY", "USAGE", key)
-  group = nil
-  for i=3,#ARGV do
-    key_match_result = {string.find(key, ARGV[i])}
-    if key_match_result[1] ~= nil then
-      group = table.concat({unpack(key_match_result, 3,  #key_match_result)},  "")
-      break
-    end
-  end
-  if group == nil then
-     group = "unclassified"
-  end
+  usage = redis.call("MEMORY", "USAGE", key) or 0
+  group = key_group(key)
   value = groups[group]
   if value == nil then
-     groups[group] = {1, usage}
-  else
-     groups[group] = {value[1] + 1, value[2] + usage}
+     value = {0, 0, 0, {}, {}}
+     groups[group] = value
+  end
+  value[1] = value[1] + 1
+  value[2] = value[2] + usage
+  if breakdown then
+    local t = redis.call("TYPE", key)["ok"]
+    local tv = value[4][t] or {0, 0}
+    value[4][t] = {tv[1] + 1, tv[2] + usage}
+    if redis.call("PTTL", key) >= 0 then
+      value[3] = value[3] + 1
+    end
+    local enc = redis.call("OBJECT", "ENCODING", key)
+    if enc then
+      value[5][enc] = (value[5][enc] or 0) + 1
+    end
   end
 end
 for group,value in pairs(groups) do
-  result[#result+1] = {group, value[1], value[2]}
+  if breakdown then
+    local types = {}
+    for t,tv in pairs(value[4]) do
+      types[#types+1] = t
+      types[#types+1] = tv[1]
+      types[#types+1] = tv[2]
+    end
+    local encodings = {}
+    for enc,count in pairs(value[5]) do
+      encodings[#encodings+1] = enc
+      encodings[#encodings+1] = count
+    end
+    result[#result+1] = {group, value[1], value[2], value[3], types, encodings}
+  else
+    result[#result+1] = {group, value[1], value[2]}
+  end
 end
-return {batch[1], result}`,
-	)
+return {batch[1], result}`
 
+// keyGroupPatternsScript groups the keys by the lua patterns of check-key-groups starting at ARGV[4]
+var keyGroupPatternsScript = redis.NewScript(0, keyGroupLuaFunctions+`
+check_key_group_patterns(4)
+local function key_group(key)
+  return get_key_group(key, 4)
+end
+`+keyGroupsAggregationLua)
+
+// scanKeyGroups runs the key group script until the SCAN is complete and passes the metrics of the key groups
+// of every batch to add, args starts with the cursor, the batch size and whether to break down the key groups
+func scanKeyGroups(c redis.Conn, script *redis.Script, args []interface{}, add func(*keyGroupMetrics)) error {
 	for {
-		arr, err := redis.Values(script.Do(c, keysAndArgs...))
+		arr, err := redis.Values(script.Do(c, args...))
 		if err != nil {
-			return nil, err
+			return err
 		}
-
 		if len(arr) != 2 {
-			return nil, fmt.Errorf("invalid response from key group metrics lua script for groups: %s", strings.Join(keyGroups, ", "))
+			return fmt.Errorf("invalid response from key group metrics lua script")
 		}
 
 		groups, _ := redis.Values(arr[1], nil)
-
 		for _, group := range groups {
-			metricsArr, _ := redis.Values(group, nil)
-			name, _ := redis.String(metricsArr[0], nil)
-			count, _ := redis.Int64(metricsArr[1], nil)
-			memoryUsage, _ := redis.Int64(metricsArr[2], nil)
-
-			if currentMetrics, ok := allGroups[name]; ok {
-				currentMetrics.count += count
-				currentMetrics.memoryUsage += memoryUsage
-			} else {
-				allGroups[name] = &keyGroupMetrics{
-					keyGroup:    name,
-					count:       count,
-					memoryUsage: memoryUsage,
-				}
+			metrics, err := parseKeyGroupMetrics(group)
+			if err != nil {
+				return err
 			}
-
+			add(metrics)
 		}
-		if keysAndArgs[0], _ = redis.Int(arr[0], nil); keysAndArgs[0].(int) == 0 {
-			break
+		if args[0], _ = redis.Int(arr[0], nil); args[0].(int) == 0 {
+			return nil
 		}
 	}
+}
+
+// keyGroupScanArgs returns the first arguments of the key group scripts
+func keyGroupScanArgs(batchSize int64, breakdown bool) []interface{} {
+	if breakdown {
+		return []interface{}{0, batchSize, "1"}
+	}
+	return []interface{}{0, batchSize, "0"}
+}
+
+func gatherKeyGroupMetrics(c redis.Conn, batchSize int64, breakdown bool, keyGroups []string) (map[string]*keyGroupMetrics, error) {
+	allGroups := make(map[string]*keyGroupMetrics)
+	args := keyGroupScanArgs(batchSize, breakdown)
+	for _, keyGroup := range keyGroups {
+		args = append(args, keyGroup)
+	}
+
+	err := scanKeyGroups(c, keyGroupPatternsScript, args, func(metrics *keyGroupMetrics) {
+		if currentMetrics, ok := allGroups[metrics.keyGroup]; ok {
+			currentMetrics.add(metrics)
+		} else {
+			allGroups[metrics.keyGroup] = metrics
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("key group metrics for groups %s: %s", strings.Join(keyGroups, ", "), err)
+	}
 	return allGroups, nil
 }
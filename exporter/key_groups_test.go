@@ -1,6 +1,7 @@
 package exporter
 
 import (
+	"net/http/httptest"
 	"os"
 	"reflect"
 	"strconv"
@@ -175,3 +176,104 @@ func TestKeyGroupMetrics(t *testing.T) {
 		})
 	}
 }
+
+func TestParseKeyGroupMetrics(t *testing.T) {
+	m, err := parseKeyGroupMetrics([]interface{}{[]byte("sessions"), int64(3), int64(300)})
+	if err != nil {
+		t.Fatalf("parseKeyGroupMetrics() err: %s", err)
+	}
+	if want := (&keyGroupMetrics{keyGroup: "sessions", count: 3, memoryUsage: 300}); !reflect.DeepEqual(m, want) {
+		t.Errorf("want: %#v, got: %#v", want, m)
+	}
+
+	m, err = parseKeyGroupMetrics([]interface{}{
+		[]byte("sessions"), int64(3), int64(300), int64(1),
+		[]interface{}{[]byte("hash"), int64(2), int64(250), []byte("string"), int64(1), int64(50)},
+		[]interface{}{[]byte("listpack"), int64(2), []byte("embstr"), int64(1)},
+	})
+	if err != nil {
+		t.Fatalf("parseKeyGroupMetrics() err: %s", err)
+	}
+	other := &keyGroupMetrics{
+		keyGroup:     "carts",
+		count:        2,
+		memoryUsage:  100,
+		withTTLCount: 2,
+		types:        map[string]*keyGroupTypeMetrics{"hash": {count: 2, memoryUsage: 100}},
+		encodings:    map[string]int64{"hashtable": 2},
+	}
+	m.add(other)
+	want := &keyGroupMetrics{
+		keyGroup:     "sessions",
+		count:        5,
+		memoryUsage:  400,
+		withTTLCount: 3,
+		types: map[string]*keyGroupTypeMetrics{
+			"hash":   {count: 4, memoryUsage: 350},
+			"string": {count: 1, memoryUsage: 50},
+		},
+		encodings: map[string]int64{"listpack": 2, "embstr": 1, "hashtable": 2},
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("want: %#v, got: %#v", want, m)
+	}
+
+	if _, err := parseKeyGroupMetrics([]interface{}{[]byte("sessions"), int64(3)}); err == nil {
+		t.Errorf("expected error for incomplete key group metrics")
+	}
+}
+
+func TestKeyGroupBreakdownMetrics(t *testing.T) {
+	if os.Getenv("TEST_REDIS_URI") == "" {
+		t.Skipf("TEST_REDIS_URI not set - skipping")
+	}
+	addr := os.Getenv("TEST_REDIS_URI")
+
+	c, err := redis.DialURL(addr)
+	if err != nil {
+		t.Fatalf("Couldn't connect to %#v: %#v", addr, err)
+	}
+	defer c.Close()
+
+	if _, err := doRedisCmd(c, "SELECT", dbNumStr); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"breakdown_sessions:1", "breakdown_sessions:2"} {
+		if _, err := doRedisCmd(c, "SET", key, "abc"); err != nil {
+			t.Fatal(err)
+		}
+		defer doRedisCmd(c, "DEL", key)
+	}
+	if _, err := doRedisCmd(c, "EXPIRE", "breakdown_sessions:1", 300); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := doRedisCmd(c, "HSET", "breakdown_sessions:3", "user", "abc"); err != nil {
+		t.Fatal(err)
+	}
+	defer doRedisCmd(c, "DEL", "breakdown_sessions:3")
+
+	e, _ := NewRedisExporter(addr, Options{
+		Namespace:            "test",
+		Registry:             prometheus.NewRegistry(),
+		CheckKeyGroups:       "^(breakdown_sessions):",
+		CheckKeysBatchSize:   1000,
+		MaxDistinctKeyGroups: 100,
+		KeyGroupsBreakdown:   true,
+	})
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+
+	body := downloadURL(t, ts.URL+"/metrics")
+	for _, want := range []string{
+		`test_key_group_type_count{db="db11",key_group="breakdown_sessions",type="string"} 2`,
+		`test_key_group_type_count{db="db11",key_group="breakdown_sessions",type="hash"} 1`,
+		`test_key_group_type_memory_usage_bytes{db="db11",key_group="breakdown_sessions",type="hash"}`,
+		`test_key_group_with_ttl_count{db="db11",key_group="breakdown_sessions"} 1`,
+		`test_key_group_without_ttl_count{db="db11",key_group="breakdown_sessions"} 2`,
+		`test_key_group_encoding_count{db="db11",encoding="embstr",key_group="breakdown_sessions"} 2`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("want metrics to include %s, have:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package exporter
+
+import (
+	"fmt"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// upper bounds (in seconds) of the buckets used for the sampled_keys_idle_seconds histogram,
+// from one hour to 90 days
+var keyIdleTimeBuckets = []float64{3600, 21600, 86400, 259200, 604800, 1209600, 2592000, 7776000}
+
+type keyIdleTimeDBState struct {
+	cursor    int64
+	idleTimes *keyGroupHistograms
+}
+
+func newKeyIdleTimeDBState() *keyIdleTimeDBState {
+	return &keyIdleTimeDBState{idleTimes: newKeyGroupHistograms(keyIdleTimeBuckets)}
+}
+
+func (s *keyIdleTimeDBState) add(k sampledKey, maxGroups int64) {
+	if k.idle < 0 {
+		return
+	}
+	_, h := s.idleTimes.get(k.keyGroup, maxGroups)
+	h.observe(keyIdleTimeBuckets, k.idle)
+}
+
+func (e *Exporter) extractKeyIdleTimeMetrics(ch chan<- prometheus.Metric, c redis.Conn, dbCount int, maxmemoryPolicy string) {
+	if isLFUPolicy(maxmemoryPolicy) {
+		// with an LFU policy Redis tracks the access frequency instead of the idle time
+		log.Debugf("Key idle time not supported with maxmemory-policy %q", maxmemoryPolicy)
+		e.keyIdle = nil
+		return
+	}
+
+	if e.keyIdle == nil {
+		e.keyIdle = map[int]*keyIdleTimeDBState{}
+	}
+
+	keyGroups := e.parseKeyGroups()
+
+	for db := 0; db < dbCount; db++ {
+		if _, err := doRedisCmd(c, "SELECT", db); err != nil {
+			log.Errorf("Couldn't select database %d when sampling key idle times.", db)
+			continue
+		}
+		if size, err := redis.Int64(doRedisCmd(c, "DBSIZE")); err != nil || size == 0 {
+			delete(e.keyIdle, db)
+			continue
+		}
+
+		state, ok := e.keyIdle[db]
+		if !ok {
+			state = newKeyIdleTimeDBState()
+			e.keyIdle[db] = state
+		}
+
+		cursor, err := sampleKeys(c, state.cursor, e.newKeySampleBudget(), []string{sampleAttrIdle}, keyGroups, func(k sampledKey) {
+			state.add(k, e.options.MaxDistinctKeyGroups)
+		})
+		if err != nil {
+			log.Errorf("Error sampling key idle times for db %d: %s", db, err)
+			continue
+		}
+		state.cursor = cursor
+
+		dbLabel := fmt.Sprintf("db%d", db)
+		for group, h := range state.idleTimes.histograms {
+			e.registerConstHistogram(ch, "sampled_keys_idle_seconds", h.count, h.sum, h.cumulativeBuckets(keyIdleTimeBuckets), dbLabel, group)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package exporter
+
+import (
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestKeyIdleTimeDBState(t *testing.T) {
+	s := newKeyIdleTimeDBState()
+	for _, k := range []sampledKey{
+		{key: "session:1", keyGroup: "session", idle: 10},
+		{key: "session:2", keyGroup: "session", idle: 100000},
+		{key: "user:1", keyGroup: "user", idle: 5000000},
+		// idle time not tracked
+		{key: "user:2", keyGroup: "user", idle: -1},
+	} {
+		s.add(k, 100)
+	}
+
+	if h := s.idleTimes.histograms["session"]; h == nil || h.count != 2 || h.sum != 100010 {
+		t.Errorf("unexpected histogram for key group session: %#v", h)
+	}
+	if h := s.idleTimes.histograms["user"]; h == nil || h.count != 1 {
+		t.Errorf("unexpected histogram for key group user: %#v", h)
+	}
+	if buckets := s.idleTimes.histograms["session"].cumulativeBuckets(keyIdleTimeBuckets); buckets[3600] != 1 || buckets[259200] != 2 {
+		t.Errorf("unexpected cumulative buckets: %#v", buckets)
+	}
+}
+
+func TestKeyIdleTimeMetrics(t *testing.T) {
+	if os.Getenv("TEST_REDIS_URI") == "" {
+		t.Skipf("TEST_REDIS_URI not set - skipping")
+	}
+	addr := os.Getenv("TEST_REDIS_URI")
+
+	setupDBKeys(t, addr)
+	defer deleteKeysFromDB(t, addr)
+
+	e, _ := NewRedisExporter(addr, Options{
+		Namespace:        "test",
+		Registry:         prometheus.NewRegistry(),
+		CheckKeyIdleTime: true,
+		CheckKeyGroups:   "^(key_exp)_.+$",
+	})
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+
+	body := downloadURL(t, ts.URL+"/metrics")
+	for _, want := range []string{
+		`test_sampled_keys_idle_seconds_bucket{db="db11",key_group="key_exp",le="3600"} 5`,
+		`test_sampled_keys_idle_seconds_count{db="db11",key_group="unclassified"} 6`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("want metrics to include %s, have:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package exporter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// keyLabelMetrics are the metrics of check-keys, count-keys and check-streams that get the labels of
+// key-label-regexes, the key (or stream) is always the second label. The keys matching a regex are
+// exported as <metric>_by_labels so every metric keeps a single label set. Metrics that can't be summed
+// like IDs and idle times keep the key label and don't get additional labels.
+var keyLabelMetrics = map[string]bool{
+	"key_size":                               true,
+	"key_value":                              true,
+	"keys_count":                             true,
+	"stream_length":                          true,
+	"stream_radix_tree_keys":                 true,
+	"stream_radix_tree_nodes":                true,
+	"stream_groups":                          true,
+	"stream_group_consumers":                 true,
+	"stream_group_messages_pending":          true,
+	"stream_group_consumer_messages_pending": true,
+}
+
+var reservedKeyLabels = map[string]bool{"db": true, "key": true, "stream": true, "group": true, "consumer": true}
+
+// keyLabelRegex turns the named capture groups of a regex into labels of the metrics of the matching keys
+type keyLabelRegex struct {
+	re     *regexp.Regexp
+	labels []string
+	// indexes of the named capture groups
+	groups []int
+}
+
+func parseKeyLabelRegexes(s string) ([]*keyLabelRegex, error) {
+	var res []*keyLabelRegex
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %q: %s", p, err)
+		}
+
+		r := &keyLabelRegex{re: re}
+		for i, name := range re.SubexpNames() {
+			if name == "" {
+				continue
+			}
+			if reservedKeyLabels[name] {
+				return nil, fmt.Errorf("regex %q: %s can't be used as label", p, name)
+			}
+			r.labels = append(r.labels, name)
+			r.groups = append(r.groups, i)
+		}
+		if len(r.labels) == 0 {
+			return nil, fmt.Errorf("regex %q has no named capture groups", p)
+		}
+		res = append(res, r)
+	}
+	return res, nil
+}
+
+// keyLabelNames returns the labels of all regexes, every <metric>_by_labels metric has all of them so
+// the keys of regexes with different capture groups can be exported as the same metric
+func keyLabelNames(regexes []*keyLabelRegex) []string {
+	var res []string
+	seen := map[string]bool{}
+	for _, r := range regexes {
+		for _, l := range r.labels {
+			if !seen[l] {
+				seen[l] = true
+				res = append(res, l)
+			}
+		}
+	}
+	return res
+}
+
+// keyLabelsMetricLabels returns the label names of a <metric>_by_labels metric, the key label is
+// replaced by the labels of the regexes when it's dropped
+func keyLabelsMetricLabels(metricLabels []string, names []string, dropKey bool) []string {
+	res := append([]string{}, metricLabels[:1]...)
+	if !dropKey {
+		res = append(res, metricLabels[1])
+	}
+	res = append(res, metricLabels[2:]...)
+	return append(res, names...)
+}
+
+// labelValues returns the values of the labels of keyLabelsMetricLabels, names the regex doesn't have are empty
+func (r *keyLabelRegex) labelValues(labelValues []string, captures []string, names []string, dropKey bool) []string {
+	res := append([]string{}, labelValues[:1]...)
+	if !dropKey {
+		res = append(res, labelValues[1])
+	}
+	res = append(res, labelValues[2:]...)
+	for _, name := range names {
+		val := ""
+		for i, l := range r.labels {
+			if l == name {
+				val = captures[r.groups[i]]
+				break
+			}
+		}
+		res = append(res, val)
+	}
+	return res
+}
+
+func (e *Exporter) matchKeyLabelRegex(key string) (*keyLabelRegex, []string) {
+	for _, r := range e.keyLabelRegexes {
+		if captures := r.re.FindStringSubmatch(key); captures != nil {
+			return r, captures
+		}
+	}
+	return nil, nil
+}
+
+type keyLabelsSum struct {
+	desc        *prometheus.Desc
+	labelValues []string
+	val         float64
+}
+
+// keyLabelsCollector exports the metrics of keys with the labels of the first matching key-label-regexes regex,
+// the values of all keys with the same labels are summed and exported as <metric>_by_labels by flush. Without
+// key-label-regexes-drop-key the key is one of the labels so only the values of count-keys patterns are summed.
+type keyLabelsCollector struct {
+	e    *Exporter
+	sums map[string]*keyLabelsSum
+	ids  []string
+}
+
+func (e *Exporter) newKeyLabelsCollector() *keyLabelsCollector {
+	return &keyLabelsCollector{e: e, sums: map[string]*keyLabelsSum{}}
+}
+
+// add exports the metric of key, labelValues[1] is the value of the key (or stream) label
+func (k *keyLabelsCollector) add(ch chan<- prometheus.Metric, metric string, key string, val float64, labelValues ...string) {
+	var r *keyLabelRegex
+	var captures []string
+	if keyLabelMetrics[metric] && len(labelValues) > 1 {
+		r, captures = k.e.matchKeyLabelRegex(key)
+	}
+	if r == nil {
+		k.e.registerConstMetricGauge(ch, metric, val, labelValues...)
+		return
+	}
+
+	values := r.labelValues(labelValues, captures, k.e.keyLabelNames, k.e.options.KeyLabelRegexesDropKey)
+	id := metric + "\x00" + strings.Join(values, "\x00")
+	if s, ok := k.sums[id]; ok {
+		s.val += val
+		return
+	}
+	k.sums[id] = &keyLabelsSum{desc: k.e.keyLabelDescs[metric], labelValues: values, val: val}
+	k.ids = append(k.ids, id)
+}
+
+func (k *keyLabelsCollector) flush(ch chan<- prometheus.Metric) {
+	for _, id := range k.ids {
+		s := k.sums[id]
+		if m, err := prometheus.NewConstMetric(s.desc, prometheus.GaugeValue, s.val, s.labelValues...); err == nil {
+			ch <- m
+		}
+	}
+	k.sums = map[string]*keyLabelsSum{}
+	k.ids = nil
+}
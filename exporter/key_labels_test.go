@@ -0,0 +1,159 @@
+package exporter
+
+import (
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestParseKeyLabelRegexes(t *testing.T) {
+	regexes, err := parseKeyLabelRegexes(`^queue:(?P<service>[^:]+):(?P<priority>[^:]+)$, ^(?P<app>[a-z]+):`)
+	if err != nil {
+		t.Fatalf("parseKeyLabelRegexes() err: %s", err)
+	}
+	if len(regexes) != 2 || !reflect.DeepEqual(regexes[0].labels, []string{"service", "priority"}) || !reflect.DeepEqual(regexes[0].groups, []int{1, 2}) {
+		t.Errorf("unexpected regexes: %#v", regexes)
+	}
+
+	for _, s := range []string{`^queue:(?P<service>[^:]+`, `^queue:([^:]+)$`, `^(?P<db>[a-z]+):`, `^(?P<key>.+)$`} {
+		if _, err := parseKeyLabelRegexes(s); err == nil {
+			t.Errorf("expected error for %s", s)
+		}
+	}
+}
+
+func TestKeyLabelsCollector(t *testing.T) {
+	regexes := `^queue:(?P<service>[^:]+):(?P<priority>[^:]+)$`
+	for _, tst := range []struct {
+		name    string
+		dropKey bool
+		want    map[string]float64
+	}{
+		{
+			name: "keep-key",
+			want: map[string]float64{
+				`key_size_by_labels{db="db0",key="queue:billing:high",priority="high",service="billing"}`: 3,
+				`key_size_by_labels{db="db0",key="queue:billing:low",priority="low",service="billing"}`:   5,
+				`key_size_by_labels{db="db0",key="queue:mail:low",priority="low",service="mail"}`:         1,
+				`key_size{db="db0",key="other"}`: 7,
+				`stream_group_consumers_by_labels{db="db0",group="g1",priority="low",service="mail",stream="queue:mail:low"}`: 2,
+				`stream_last_generated_id{db="db0",stream="queue:mail:low"}`:                                                  42,
+			},
+		},
+		{
+			name:    "drop-key",
+			dropKey: true,
+			want: map[string]float64{
+				`key_size_by_labels{db="db0",priority="high",service="billing"}`:                      3,
+				`key_size_by_labels{db="db0",priority="low",service="billing"}`:                       5,
+				`key_size_by_labels{db="db0",priority="low",service="mail"}`:                          1,
+				`key_size{db="db0",key="other"}`:                                                      7,
+				`stream_group_consumers_by_labels{db="db0",group="g1",priority="low",service="mail"}`: 2,
+				`stream_last_generated_id{db="db0",stream="queue:mail:low"}`:                          42,
+			},
+		},
+	} {
+		t.Run(tst.name, func(t *testing.T) {
+			e, err := NewRedisExporter("", Options{Namespace: "test", KeyLabelRegexes: regexes, KeyLabelRegexesDropKey: tst.dropKey})
+			if err != nil {
+				t.Fatalf("NewRedisExporter() err: %s", err)
+			}
+
+			got := collectGauges(func(ch chan<- prometheus.Metric) {
+				kc := e.newKeyLabelsCollector()
+				for _, k := range []struct {
+					key string
+					val float64
+				}{{"queue:billing:high", 3}, {"queue:billing:low", 5}, {"queue:mail:low", 1}, {"other", 7}} {
+					kc.add(ch, "key_size", k.key, k.val, "db0", k.key)
+				}
+				kc.add(ch, "stream_group_consumers", "queue:mail:low", 2, "db0", "queue:mail:low", "g1")
+				kc.add(ch, "stream_last_generated_id", "queue:mail:low", 42, "db0", "queue:mail:low")
+				kc.flush(ch)
+			})
+			if !reflect.DeepEqual(got, tst.want) {
+				t.Errorf("want: %#v, got: %#v", tst.want, got)
+			}
+		})
+	}
+
+	// with the key label dropped the values of keys with the same labels are summed
+	e, _ := NewRedisExporter("", Options{Namespace: "test", KeyLabelRegexes: `^queue:(?P<service>[^:]+):`, KeyLabelRegexesDropKey: true})
+	got := collectGauges(func(ch chan<- prometheus.Metric) {
+		kc := e.newKeyLabelsCollector()
+		kc.add(ch, "key_size", "queue:billing:high", 3, "db0", "queue:billing:high")
+		kc.add(ch, "key_size", "queue:billing:low", 5, "db0", "queue:billing:low")
+		kc.flush(ch)
+	})
+	want := map[string]float64{`key_size_by_labels{db="db0",service="billing"}`: 8}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want: %#v, got: %#v", want, got)
+	}
+
+	// keys of regexes with different capture groups are exported as the same metric with all labels
+	e, _ = NewRedisExporter("", Options{Namespace: "test", KeyLabelRegexes: `^queue:(?P<service>[^:]+):, ^cache:(?P<tenant>[^:]+):`, KeyLabelRegexesDropKey: true})
+	got = collectGauges(func(ch chan<- prometheus.Metric) {
+		kc := e.newKeyLabelsCollector()
+		kc.add(ch, "key_size", "queue:billing:high", 3, "db0", "queue:billing:high")
+		kc.add(ch, "key_size", "cache:acme:1", 5, "db0", "cache:acme:1")
+		kc.flush(ch)
+	})
+	want = map[string]float64{
+		`key_size_by_labels{db="db0",service="billing",tenant=""}`: 3,
+		`key_size_by_labels{db="db0",service="",tenant="acme"}`:    5,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want: %#v, got: %#v", want, got)
+	}
+}
+
+func TestCountKeysWithKeyLabels(t *testing.T) {
+	if os.Getenv("TEST_REDIS_URI") == "" {
+		t.Skipf("TEST_REDIS_URI not set - skipping")
+	}
+	addr := os.Getenv("TEST_REDIS_URI")
+
+	c, err := redis.DialURL(addr)
+	if err != nil {
+		t.Fatalf("Couldn't connect to %#v: %#v", addr, err)
+	}
+	defer c.Close()
+
+	if _, err := doRedisCmd(c, "SELECT", dbNumStr); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"labels_queue:billing:high", "labels_queue:billing:low", "labels_queue:mail:low", "labels_queue:other"} {
+		if _, err := doRedisCmd(c, "RPUSH", key, "job"); err != nil {
+			t.Fatal(err)
+		}
+		defer doRedisCmd(c, "DEL", key)
+	}
+
+	e, _ := NewRedisExporter(addr, Options{
+		Namespace:              "test",
+		Registry:               prometheus.NewRegistry(),
+		CountKeys:              dbNumStrFull + "=labels_queue:*",
+		CheckKeysBatchSize:     1000,
+		KeyLabelRegexes:        `^labels_queue:(?P<service>[^:]+):(?P<priority>[^:]+)$`,
+		KeyLabelRegexesDropKey: true,
+	})
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+
+	body := downloadURL(t, ts.URL+"/metrics")
+	for _, want := range []string{
+		`test_keys_count_by_labels{db="db11",priority="high",service="billing"} 1`,
+		`test_keys_count_by_labels{db="db11",priority="low",service="billing"} 1`,
+		`test_keys_count_by_labels{db="db11",priority="low",service="mail"} 1`,
+		`test_keys_count{db="db11",key="labels_queue:*"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("want metrics to include %s, have:\n%s", want, body)
+		}
+	}
+}
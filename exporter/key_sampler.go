@@ -0,0 +1,312 @@
+package exporter
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	log "github.com/sirupsen/logrus"
+)
+
+// attributes the key sampler script can return for every sampled key besides its name and type
+const (
+	sampleAttrMemory = "memory"
+	sampleAttrSize   = "size"
+	sampleAttrFreq   = "freq"
+	sampleAttrTTL    = "ttl"
+	sampleAttrIdle   = "idle"
+)
+
+type sampledKey struct {
+	key         string
+	keyType     string
+	keyGroup    string
+	memoryUsage float64
+	size        float64
+	freq        float64
+	// TTL in seconds, -1 if the key doesn't expire
+	ttl float64
+	// idle time in seconds, -1 if not tracked (with an LFU maxmemory-policy)
+	idle float64
+}
+
+// keySamplerScript scans one batch of keys and returns the name, type, the requested attributes and
+// optionally the key group of every key so sampling a batch only takes a single round trip.
+// ARGV: cursor, count, MEMORY USAGE samples, number of attributes, attributes..., key group patterns...
+var keySamplerScript = redis.NewScript(
+	0,
+	keyGroupLuaFunctions+`
+local batch = redis.call("SCAN", ARGV[1], "COUNT", ARGV[2])
+local sizeCmds = {string="STRLEN", list="LLEN", set="SCARD", zset="ZCARD", hash="HLEN", stream="XLEN"}
+local lastAttr = 4 + tonumber(ARGV[4])
+local result = {}
+local keyType = nil
+local sample = {}
+local idle = 0
+check_key_group_patterns(lastAttr + 1)
+for i,key in ipairs(batch[2]) do
+  keyType = redis.call("TYPE", key)["ok"]
+  sample = {key, keyType}
+  for j=5,lastAttr do
+    if keyType == "none" then
+      sample[#sample+1] = 0
+    elseif ARGV[j] == "memory" then
+      sample[#sample+1] = redis.call("MEMORY", "USAGE", key, "SAMPLES", ARGV[3]) or 0
+    elseif ARGV[j] == "size" and sizeCmds[keyType] ~= nil then
+      sample[#sample+1] = redis.call(sizeCmds[keyType], key)
+    elseif ARGV[j] == "freq" then
+      sample[#sample+1] = redis.call("OBJECT", "FREQ", key) or 0
+    elseif ARGV[j] == "ttl" then
+      sample[#sample+1] = redis.call("PTTL", key)
+    elseif ARGV[j] == "idle" then
+      idle = redis.pcall("OBJECT", "IDLETIME", key)
+      if type(idle) ~= "number" then
+        idle = -1
+      end
+      sample[#sample+1] = idle
+    else
+      sample[#sample+1] = 0
+    end
+  end
+  if #ARGV > lastAttr then
+    sample[#sample+1] = get_key_group(key, lastAttr + 1)
+  end
+  result[#result+1] = sample
+end
+return {batch[1], result}`,
+)
+
+type keySampleBudget struct {
+	maxKeys   int64
+	deadline  time.Time
+	batchSize int64
+	samples   int64
+}
+
+func (e *Exporter) newKeySampleBudget() keySampleBudget {
+	budget := keySampleBudget{
+		maxKeys:   e.options.SampleKeysBudget,
+		deadline:  time.Now().Add(e.options.SampleKeysTimeout),
+		batchSize: e.options.CheckKeysBatchSize,
+		// same as the default of MEMORY USAGE
+		samples: 5,
+	}
+	if budget.batchSize <= 0 {
+		budget.batchSize = 1000
+	}
+	return budget
+}
+
+// sampleKeys walks the keyspace of the currently selected database starting at cursor and calls fn
+// for every sampled key until either the whole keyspace was scanned or the budget is used up.
+// If keyGroups is set the sampled keys are grouped using these lua patterns, see check-key-groups.
+// It returns the cursor to continue with during the next scrape, zero means the scan is complete.
+func sampleKeys(c redis.Conn, cursor int64, budget keySampleBudget, attrs []string, keyGroups []string, fn func(k sampledKey)) (int64, error) {
+	sampled := int64(0)
+	for {
+		count := budget.batchSize
+		if remaining := budget.maxKeys - sampled; remaining < count {
+			count = remaining
+		}
+
+		args := []interface{}{cursor, count, budget.samples, len(attrs)}
+		for _, a := range attrs {
+			args = append(args, a)
+		}
+		for _, g := range keyGroups {
+			args = append(args, g)
+		}
+		arr, err := redis.Values(keySamplerScript.Do(c, args...))
+		if err != nil {
+			return cursor, err
+		}
+		if len(arr) != 2 {
+			return cursor, fmt.Errorf("invalid response from key sampler lua script")
+		}
+
+		keys, _ := redis.Values(arr[1], nil)
+		for _, k := range keys {
+			s, ok := parseSampledKey(k, attrs, len(keyGroups) > 0)
+			if !ok {
+				log.Debugf("sampleKeys() invalid key sample: %#v", k)
+				continue
+			}
+			if s.keyType == "none" {
+				// key expired or got deleted since it was returned by SCAN
+				continue
+			}
+			fn(s)
+			sampled++
+		}
+
+		if cursor, err = redis.Int64(arr[0], nil); err != nil {
+			return 0, err
+		}
+		if cursor == 0 || sampled >= budget.maxKeys || time.Now().After(budget.deadline) {
+			return cursor, nil
+		}
+	}
+}
+
+func parseSampledKey(reply interface{}, attrs []string, grouped bool) (s sampledKey, ok bool) {
+	vals, err := redis.Values(reply, nil)
+	if err != nil {
+		return s, false
+	}
+	if grouped {
+		if len(vals) != len(attrs)+3 {
+			return s, false
+		}
+		if s.keyGroup, err = redis.String(vals[len(vals)-1], nil); err != nil {
+			return s, false
+		}
+	} else if len(vals) != len(attrs)+2 {
+		return s, false
+	}
+	if s.key, err = redis.String(vals[0], nil); err != nil {
+		return s, false
+	}
+	if s.keyType, err = redis.String(vals[1], nil); err != nil {
+		return s, false
+	}
+	for idx, a := range attrs {
+		val, err := redis.Int64(vals[idx+2], nil)
+		if err != nil {
+			return s, false
+		}
+		switch a {
+		case sampleAttrMemory:
+			s.memoryUsage = float64(val)
+		case sampleAttrSize:
+			s.size = float64(val)
+		case sampleAttrFreq:
+			s.freq = float64(val)
+		case sampleAttrTTL:
+			// PTTL returns -1 for keys without TTL
+			s.ttl = -1
+			if val >= 0 {
+				s.ttl = float64(val) / 1000
+			}
+		case sampleAttrIdle:
+			s.idle = float64(val)
+		}
+	}
+	return s, true
+}
+
+type topKeysEntry struct {
+	sampledKey
+	pass int64
+}
+
+// sampledTopKeys keeps the top keys seen across scrapes, the keyspace is scanned a bit further during
+// every scrape and keys that weren't seen again during a complete pass over the keyspace are dropped.
+type sampledTopKeys struct {
+	cursor int64
+	pass   int64
+	top    map[string]*topKeysEntry
+}
+
+func newSampledTopKeys() *sampledTopKeys {
+	return &sampledTopKeys{top: map[string]*topKeysEntry{}}
+}
+
+func (s *sampledTopKeys) add(k sampledKey) {
+	s.top[k.key] = &topKeysEntry{sampledKey: k, pass: s.pass}
+}
+
+// setCursor remembers where to continue scanning, once a pass over the keyspace is complete all
+// keys that weren't sampled during that pass are dropped.
+func (s *sampledTopKeys) setCursor(cursor int64) {
+	s.cursor = cursor
+	if cursor != 0 {
+		return
+	}
+	for key, entry := range s.top {
+		if entry.pass < s.pass {
+			delete(s.top, key)
+		}
+	}
+	s.pass++
+}
+
+// trim only keeps the topN keys with the highest value per group
+func (s *sampledTopKeys) trim(topN int64, groupBy func(k sampledKey) string, value func(k sampledKey) float64) {
+	groups := map[string][]*topKeysEntry{}
+	for _, entry := range s.top {
+		group := groupBy(entry.sampledKey)
+		groups[group] = append(groups[group], entry)
+	}
+	for _, entries := range groups {
+		if int64(len(entries)) <= topN {
+			continue
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			if value(entries[i].sampledKey) == value(entries[j].sampledKey) {
+				return entries[i].key < entries[j].key
+			}
+			return value(entries[i].sampledKey) > value(entries[j].sampledKey)
+		})
+		for _, entry := range entries[topN:] {
+			delete(s.top, entry.key)
+		}
+	}
+}
+
+type sampleHistogram struct {
+	count   uint64
+	sum     float64
+	buckets []uint64
+}
+
+func newSampleHistogram(bounds []float64) *sampleHistogram {
+	return &sampleHistogram{buckets: make([]uint64, len(bounds))}
+}
+
+func (h *sampleHistogram) observe(bounds []float64, val float64) {
+	h.count++
+	h.sum += val
+	if idx := sort.SearchFloat64s(bounds, val); idx < len(bounds) {
+		h.buckets[idx]++
+	}
+}
+
+// cumulativeBuckets returns the buckets as expected by prometheus.NewConstHistogram
+func (h *sampleHistogram) cumulativeBuckets(bounds []float64) map[float64]uint64 {
+	res := map[float64]uint64{}
+	var cnt uint64
+	for idx, upperBound := range bounds {
+		cnt += h.buckets[idx]
+		res[upperBound] = cnt
+	}
+	return res
+}
+
+// keyGroupHistograms keeps one histogram per key group, to limit the cardinality all key groups
+// seen after maxGroups distinct key groups are tracked end up in the "overflow" key group.
+type keyGroupHistograms struct {
+	bounds     []float64
+	histograms map[string]*sampleHistogram
+}
+
+func newKeyGroupHistograms(bounds []float64) *keyGroupHistograms {
+	return &keyGroupHistograms{bounds: bounds, histograms: map[string]*sampleHistogram{}}
+}
+
+// get returns the name of the key group the sample is accounted to and its histogram, samples
+// without key group are never accounted to overflow
+func (h *keyGroupHistograms) get(group string, maxGroups int64) (string, *sampleHistogram) {
+	if hist, ok := h.histograms[group]; ok {
+		return group, hist
+	}
+	if group != "" && int64(len(h.histograms)) >= maxGroups {
+		group = "overflow"
+		if hist, ok := h.histograms[group]; ok {
+			return group, hist
+		}
+	}
+	hist := newSampleHistogram(h.bounds)
+	h.histograms[group] = hist
+	return group, hist
+}
@@ -0,0 +1,60 @@
+package exporter
+
+import (
+	"testing"
+)
+
+func TestParseSampledKey(t *testing.T) {
+	for _, tst := range []struct {
+		name    string
+		reply   interface{}
+		attrs   []string
+		grouped bool
+		wantOk  bool
+		want    sampledKey
+	}{
+		{
+			name:   "memory and size",
+			reply:  []interface{}{[]byte("k"), []byte("list"), int64(120), int64(3)},
+			attrs:  []string{sampleAttrMemory, sampleAttrSize},
+			wantOk: true,
+			want:   sampledKey{key: "k", keyType: "list", memoryUsage: 120, size: 3},
+		},
+		{
+			name:    "ttl with key group",
+			reply:   []interface{}{[]byte("session:1"), []byte("string"), int64(1500), []byte("session")},
+			attrs:   []string{sampleAttrTTL},
+			grouped: true,
+			wantOk:  true,
+			want:    sampledKey{key: "session:1", keyType: "string", keyGroup: "session", ttl: 1.5},
+		},
+		{
+			name:   "no ttl",
+			reply:  []interface{}{[]byte("k"), []byte("string"), int64(-1)},
+			attrs:  []string{sampleAttrTTL},
+			wantOk: true,
+			want:   sampledKey{key: "k", keyType: "string", ttl: -1},
+		},
+		{
+			name:    "missing key group",
+			reply:   []interface{}{[]byte("k"), []byte("string"), int64(-1)},
+			attrs:   []string{sampleAttrTTL},
+			grouped: true,
+		},
+		{
+			name:  "invalid attribute",
+			reply: []interface{}{[]byte("k"), []byte("string"), []byte("nope")},
+			attrs: []string{sampleAttrFreq},
+		},
+	} {
+		t.Run(tst.name, func(t *testing.T) {
+			got, ok := parseSampledKey(tst.reply, tst.attrs, tst.grouped)
+			if ok != tst.wantOk {
+				t.Fatalf("expected ok: %t, got: %t", tst.wantOk, ok)
+			}
+			if ok && got != tst.want {
+				t.Errorf("expected %#v, got: %#v", tst.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package exporter
+
+import (
+	"fmt"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// upper bounds (in seconds) of the buckets used for the sampled_keys_ttl_seconds histogram,
+// from one minute to 30 days
+var keyTTLBuckets = []float64{60, 300, 900, 3600, 21600, 86400, 604800, 2592000}
+
+type keyTTLDBState struct {
+	cursor int64
+	ttls   *keyGroupHistograms
+	// number of sampled keys without TTL per key group
+	noTTL map[string]uint64
+}
+
+func newKeyTTLDBState() *keyTTLDBState {
+	return &keyTTLDBState{
+		ttls:  newKeyGroupHistograms(keyTTLBuckets),
+		noTTL: map[string]uint64{},
+	}
+}
+
+func (s *keyTTLDBState) add(k sampledKey, maxGroups int64) {
+	group, h := s.ttls.get(k.keyGroup, maxGroups)
+	if k.ttl < 0 {
+		s.noTTL[group]++
+		return
+	}
+	h.observe(keyTTLBuckets, k.ttl)
+}
+
+func (e *Exporter) extractKeyTTLMetrics(ch chan<- prometheus.Metric, c redis.Conn, dbCount int) {
+	if e.keyTTLs == nil {
+		e.keyTTLs = map[int]*keyTTLDBState{}
+	}
+
+	var keyGroups []string
+	if e.options.TTLDistributionByKeyGroup {
+		keyGroups = e.parseKeyGroups()
+	}
+
+	for db := 0; db < dbCount; db++ {
+		if _, err := doRedisCmd(c, "SELECT", db); err != nil {
+			log.Errorf("Couldn't select database %d when sampling key TTLs.", db)
+			continue
+		}
+		if size, err := redis.Int64(doRedisCmd(c, "DBSIZE")); err != nil || size == 0 {
+			delete(e.keyTTLs, db)
+			continue
+		}
+
+		state, ok := e.keyTTLs[db]
+		if !ok {
+			state = newKeyTTLDBState()
+			e.keyTTLs[db] = state
+		}
+
+		cursor, err := sampleKeys(c, state.cursor, e.newKeySampleBudget(), []string{sampleAttrTTL}, keyGroups, func(k sampledKey) {
+			state.add(k, e.options.MaxDistinctKeyGroups)
+		})
+		if err != nil {
+			log.Errorf("Error sampling key TTLs for db %d: %s", db, err)
+			continue
+		}
+		state.cursor = cursor
+
+		dbLabel := fmt.Sprintf("db%d", db)
+		for group, h := range state.ttls.histograms {
+			e.registerConstHistogram(ch, "sampled_keys_ttl_seconds", h.count, h.sum, h.cumulativeBuckets(keyTTLBuckets), dbLabel, group)
+			e.registerConstMetric(ch, "sampled_keys_without_ttl_total", float64(state.noTTL[group]), prometheus.CounterValue, dbLabel, group)
+		}
+	}
+}
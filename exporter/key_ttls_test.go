@@ -0,0 +1,82 @@
+package exporter
+
+import (
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestKeyTTLDBState(t *testing.T) {
+	s := newKeyTTLDBState()
+	for _, k := range []sampledKey{
+		{key: "session:1", keyGroup: "session", ttl: 30},
+		{key: "session:2", keyGroup: "session", ttl: 1800},
+		{key: "session:3", keyGroup: "session", ttl: -1},
+		{key: "user:1", keyGroup: "user", ttl: -1},
+		{key: "cache:1", keyGroup: "cache", ttl: 100000},
+	} {
+		s.add(k, 2)
+	}
+
+	if len(s.ttls.histograms) != 3 {
+		t.Fatalf("expected key groups session, user and overflow, got: %#v", s.ttls.histograms)
+	}
+	if h := s.ttls.histograms["session"]; h.count != 2 || h.sum != 1830 {
+		t.Errorf("unexpected histogram for key group session: %#v", h)
+	}
+	if buckets := s.ttls.histograms["session"].cumulativeBuckets(keyTTLBuckets); buckets[60] != 1 || buckets[3600] != 2 {
+		t.Errorf("unexpected cumulative buckets: %#v", buckets)
+	}
+	if s.noTTL["session"] != 1 || s.noTTL["user"] != 1 {
+		t.Errorf("unexpected number of keys without TTL: %#v", s.noTTL)
+	}
+	if h := s.ttls.histograms["overflow"]; h.count != 1 {
+		t.Errorf("expected key group cache to be accounted to overflow, got: %#v", h)
+	}
+
+	// samples without key groups are never accounted to overflow
+	s = newKeyTTLDBState()
+	s.add(sampledKey{key: "session:1", ttl: 30}, 0)
+	if h := s.ttls.histograms[""]; h == nil || h.count != 1 {
+		t.Errorf("expected the sample in the histogram without key group, got: %#v", s.ttls.histograms)
+	}
+
+	if e, _ := NewRedisExporter("", Options{}); e.options.MaxDistinctKeyGroups != 100 {
+		t.Errorf("expected max-distinct-key-groups to default to 100, got %d", e.options.MaxDistinctKeyGroups)
+	}
+}
+
+func TestKeyTTLMetrics(t *testing.T) {
+	if os.Getenv("TEST_REDIS_URI") == "" {
+		t.Skipf("TEST_REDIS_URI not set - skipping")
+	}
+	addr := os.Getenv("TEST_REDIS_URI")
+
+	setupDBKeys(t, addr)
+	defer deleteKeysFromDB(t, addr)
+
+	e, _ := NewRedisExporter(addr, Options{
+		Namespace:                 "test",
+		Registry:                  prometheus.NewRegistry(),
+		CheckTTLDistribution:      true,
+		TTLDistributionByKeyGroup: true,
+		CheckKeyGroups:            "^(key_exp)_.+$",
+	})
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+
+	body := downloadURL(t, ts.URL+"/metrics")
+	for _, want := range []string{
+		`test_sampled_keys_ttl_seconds_bucket{db="db11",key_group="key_exp",le="300"} 5`,
+		`test_sampled_keys_ttl_seconds_count{db="db11",key_group="key_exp"} 5`,
+		`test_sampled_keys_without_ttl_total{db="db11",key_group="key_exp"} 0`,
+		`test_sampled_keys_without_ttl_total{db="db11",key_group="unclassified"} 6`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("want metrics to include %s, have:\n%s", want, body)
+		}
+	}
+}
@@ -94,6 +94,8 @@ func (e *Exporter) extractCheckKeyMetrics(ch chan<- prometheus.Metric, c redis.C
 	}
 
 	log.Debugf("allKeys: %#v", allKeys)
+	kc := e.newKeyLabelsCollector()
+	defer kc.flush(ch)
 	for _, k := range allKeys {
 		if e.options.IsCluster {
 			// Cluster mode only has one db
@@ -110,28 +112,62 @@ func (e *Exporter) extractCheckKeyMetrics(ch chan<- prometheus.Metric, c redis.C
 		switch err {
 		case errKeyTypeNotFound:
 			log.Debugf("Key '%s' not found when trying to get type and size: using default '0.0'", k.key)
-			e.registerConstMetricGauge(ch, "key_size", 0.0, dbLabel, k.key)
+			kc.add(ch, "key_size", k.key, 0.0, dbLabel, k.key)
 		case nil:
-			e.registerConstMetricGauge(ch, "key_size", info.size, dbLabel, k.key)
+			kc.add(ch, "key_size", k.key, info.size, dbLabel, k.key)
 
 			// Only run on single value strings
 			if info.keyType == "string" {
 				if strVal, err := redis.String(doRedisCmd(c, "GET", k.key)); err == nil {
 					if val, err := strconv.ParseFloat(strVal, 64); err == nil {
 						// Only record value metric if value is float-y
-						e.registerConstMetricGauge(ch, "key_value", val, dbLabel, k.key)
+						kc.add(ch, "key_value", k.key, val, dbLabel, k.key)
 					} else {
 						// if it's not float-y then we'll record the value as a string label
 						e.registerConstMetricGauge(ch, "key_value_as_string", 1.0, dbLabel, k.key, strVal)
 					}
 				}
 			}
+
+			if e.options.CheckKeysDetails {
+				e.extractKeyDetailsMetrics(ch, c, dbLabel, k.key)
+			}
 		default:
 			log.Error(err)
 		}
 	}
 }
 
+// extractKeyDetailsMetrics exports TTL, memory usage, encoding and idle time of a key, OBJECT IDLETIME
+// fails with an LFU maxmemory-policy so the idle time is skipped then
+func (e *Exporter) extractKeyDetailsMetrics(ch chan<- prometheus.Metric, c redis.Conn, dbLabel string, key string) {
+	if ttl, err := redis.Int64(doRedisCmd(c, "PTTL", key)); err == nil && ttl != -2 {
+		if ttl == -1 {
+			e.registerConstMetricGauge(ch, "key_ttl_seconds", -1, dbLabel, key)
+		} else {
+			e.registerConstMetricGauge(ch, "key_ttl_seconds", float64(ttl)/1000, dbLabel, key)
+		}
+	}
+
+	if mem, err := redis.Int64(doRedisCmd(c, "MEMORY", "USAGE", key, "SAMPLES", e.options.KeyMemoryUsageSamples)); err == nil {
+		e.registerConstMetricGauge(ch, "key_memory_usage_bytes", float64(mem), dbLabel, key)
+	} else {
+		log.Debugf("MEMORY USAGE %s err: %s", key, err)
+	}
+
+	if encoding, err := redis.String(doRedisCmd(c, "OBJECT", "ENCODING", key)); err == nil {
+		e.registerConstMetricGauge(ch, "key_encoding_info", 1, dbLabel, key, encoding)
+	} else {
+		log.Debugf("OBJECT ENCODING %s err: %s", key, err)
+	}
+
+	if idle, err := redis.Int64(doRedisCmd(c, "OBJECT", "IDLETIME", key)); err == nil {
+		e.registerConstMetricGauge(ch, "key_idle_seconds", float64(idle), dbLabel, key)
+	} else {
+		log.Debugf("OBJECT IDLETIME %s err: %s", key, err)
+	}
+}
+
 func (e *Exporter) extractCountKeysMetrics(ch chan<- prometheus.Metric, c redis.Conn) {
 	cntKeys, err := parseKeyArg(e.options.CountKeys)
 	if err != nil {
@@ -139,19 +175,144 @@ func (e *Exporter) extractCountKeysMetrics(ch chan<- prometheus.Metric, c redis.
 		return
 	}
 
+	if e.options.KeyCountEstimateSamples > 0 {
+		e.extractCountKeysEstimateMetrics(ch, c, cntKeys)
+		return
+	}
+
+	kc := e.newKeyLabelsCollector()
+	defer kc.flush(ch)
 	for _, k := range cntKeys {
 		if _, err := doRedisCmd(c, "SELECT", k.db); err != nil {
 			log.Errorf("Couldn't select database '%s' when getting stream info", k.db)
 			continue
 		}
-		cnt, err := getKeysCount(c, k.key, e.options.CheckKeysBatchSize)
+		dbLabel := "db" + k.db
+
+		if pattern, keyType := splitCountKeysType(k.key); keyType != "" {
+			e.extractCountKeysByTypeMetrics(ch, c, dbLabel, pattern, keyType)
+			continue
+		}
+
+		if len(e.keyLabelRegexes) == 0 {
+			cnt, err := getKeysCount(c, k.key, e.options.CheckKeysBatchSize)
+			if err != nil {
+				log.Errorf("couldn't get key count for '%s', err: %s", k.key, err)
+				continue
+			}
+			e.registerConstMetricGauge(ch, "keys_count", float64(cnt), dbLabel, k.key)
+			continue
+		}
+
+		// the keys matching a regex are counted per label set while scanning, the other ones as before
+		unmatched := 0
+		err := forEachScannedKey(c, k.key, e.options.CheckKeysBatchSize, func(key string) {
+			if r, _ := e.matchKeyLabelRegex(key); r == nil {
+				unmatched++
+				return
+			}
+			kc.add(ch, "keys_count", key, 1, dbLabel, k.key)
+		})
 		if err != nil {
 			log.Errorf("couldn't get key count for '%s', err: %s", k.key, err)
 			continue
 		}
-		dbLabel := "db" + k.db
-		e.registerConstMetricGauge(ch, "keys_count", float64(cnt), dbLabel, k.key)
+		e.registerConstMetricGauge(ch, "keys_count", float64(unmatched), dbLabel, k.key)
+	}
+}
+
+// countKeysTypes are the types counted by count-keys patterns ending with #*
+var countKeysTypes = []string{"string", "list", "set", "zset", "hash", "stream"}
+
+// splitCountKeysType splits the type off a count-keys pattern, e.g. user:*#hash counts only hashes and
+// user:*#* counts the keys of every type separately. Anything after the last # that's not a type is part
+// of the pattern.
+func splitCountKeysType(pattern string) (string, string) {
+	idx := strings.LastIndex(pattern, "#")
+	if idx <= 0 {
+		return pattern, ""
 	}
+	keyType := pattern[idx+1:]
+	if keyType == "*" {
+		return pattern[:idx], keyType
+	}
+	for _, t := range countKeysTypes {
+		if keyType == t {
+			return pattern[:idx], keyType
+		}
+	}
+	return pattern, ""
+}
+
+func (e *Exporter) extractCountKeysByTypeMetrics(ch chan<- prometheus.Metric, c redis.Conn, dbLabel string, pattern string, keyType string) {
+	counts, err := countKeysByType(c, pattern, keyType, e.options.CheckKeysBatchSize)
+	if err != nil {
+		log.Errorf("couldn't get key count of type %s for '%s', err: %s", keyType, pattern, err)
+		return
+	}
+
+	// with a type filter the type is exported even without keys, otherwise only the types that exist
+	if keyType != "*" {
+		counts[keyType] += 0
+	}
+	for t, cnt := range counts {
+		e.registerConstMetricGauge(ch, "keys_count_by_type", float64(cnt), dbLabel, pattern, t)
+	}
+}
+
+// countKeysByTypeScript counts the keys of a SCAN batch per type and returns {cursor, {type, count, ...}}
+var countKeysByTypeScript = redis.NewScript(0, `
+local batch = redis.call("SCAN", ARGV[1], "MATCH", ARGV[2], "COUNT", ARGV[3])
+local counts = {}
+for i,key in ipairs(batch[2]) do
+  local t = redis.call("TYPE", key)["ok"]
+  if t ~= "none" then
+    counts[t] = (counts[t] or 0) + 1
+  end
+end
+local result = {}
+for t,count in pairs(counts) do
+  result[#result+1] = t
+  result[#result+1] = count
+end
+return {batch[1], result}`)
+
+// countKeysByType counts the keys matching pattern per type in a single SCAN, for a single type SCAN's TYPE
+// option is used and with keyType "*" the keys of every batch are counted per type by countKeysByTypeScript
+func countKeysByType(c redis.Conn, pattern string, keyType string, count int64) (map[string]int64, error) {
+	counts := map[string]int64{}
+	iter := 0
+	for {
+		var arr []interface{}
+		var err error
+		if keyType == "*" {
+			arr, err = redis.Values(countKeysByTypeScript.Do(c, iter, pattern, count))
+		} else {
+			arr, err = redis.Values(doRedisCmd(c, "SCAN", iter, "MATCH", pattern, "COUNT", count, "TYPE", keyType))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error counting '%s' keys err: %s", pattern, err)
+		}
+		if len(arr) != 2 {
+			return nil, fmt.Errorf("invalid response from SCAN for pattern: %s", pattern)
+		}
+
+		values, _ := redis.Values(arr[1], nil)
+		if keyType == "*" {
+			for i := 0; i+1 < len(values); i += 2 {
+				t, _ := redis.String(values[i], nil)
+				cnt, _ := redis.Int64(values[i+1], nil)
+				counts[t] += cnt
+			}
+		} else {
+			counts[keyType] += int64(len(values))
+		}
+
+		if iter, _ = redis.Int(arr[0], nil); iter == 0 {
+			break
+		}
+	}
+	return counts, nil
 }
 
 func getKeysCount(c redis.Conn, pattern string, count int64) (int, error) {
@@ -267,3 +428,26 @@ func scanKeys(c redis.Conn, pattern string, count int64) (keys []interface{}, er
 
 	return keys, nil
 }
+
+// forEachScannedKey calls fn for every key matching pattern without keeping the keys of more than one SCAN batch
+func forEachScannedKey(c redis.Conn, pattern string, count int64, fn func(key string)) error {
+	iter := 0
+	for {
+		arr, err := redis.Values(doRedisCmd(c, "SCAN", iter, "MATCH", pattern, "COUNT", count))
+		if err != nil {
+			return fmt.Errorf("error retrieving '%s' keys err: %s", pattern, err)
+		}
+		if len(arr) != 2 {
+			return fmt.Errorf("invalid response from SCAN for pattern: %s", pattern)
+		}
+
+		keys, _ := redis.Strings(arr[1], nil)
+		for _, key := range keys {
+			fn(key)
+		}
+
+		if iter, _ = redis.Int(arr[0], nil); iter == 0 {
+			return nil
+		}
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"net/url"
 	"os"
 	"reflect"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -549,6 +551,71 @@ func TestKeySizeList(t *testing.T) {
 	}
 }
 
+func TestKeyMemoryUsageSamplesDefault(t *testing.T) {
+	for _, tst := range []struct {
+		samples int64
+		want    int64
+	}{
+		{samples: 0, want: 0},
+		{samples: 10, want: 10},
+		{samples: -1, want: 5},
+	} {
+		e, err := NewRedisExporter("", Options{KeyMemoryUsageSamples: tst.samples})
+		if err != nil {
+			t.Fatalf("NewRedisExporter() err: %s", err)
+		}
+		if e.options.KeyMemoryUsageSamples != tst.want {
+			t.Errorf("KeyMemoryUsageSamples %d: want %d, got %d", tst.samples, tst.want, e.options.KeyMemoryUsageSamples)
+		}
+	}
+}
+
+func TestKeyDetails(t *testing.T) {
+	if os.Getenv("TEST_REDIS_URI") == "" {
+		t.Skipf("TEST_REDIS_URI not set - skipping")
+	}
+
+	setupDBKeys(t, os.Getenv("TEST_REDIS_URI"))
+	defer deleteKeysFromDB(t, os.Getenv("TEST_REDIS_URI"))
+
+	e, _ := NewRedisExporter(
+		os.Getenv("TEST_REDIS_URI"),
+		Options{
+			Namespace:        "test",
+			Registry:         prometheus.NewRegistry(),
+			CheckSingleKeys:  dbNumStrFull + "=" + listKeys[0] + "," + dbNumStrFull + "=" + url.QueryEscape(keysExpiring[0]),
+			CheckKeysDetails: true,
+		},
+	)
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+
+	body := downloadURL(t, ts.URL+"/metrics")
+	for _, want := range []string{
+		`test_key_ttl_seconds{db="db11",key="beatles_list"} -1`,
+		`test_key_memory_usage_bytes{db="db11",key="beatles_list"}`,
+		`test_key_idle_seconds{db="db11",key="beatles_list"}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("want metrics to include %s, have:\n%s", want, body)
+		}
+	}
+
+	// the encoding of lists depends on the Redis version
+	if !regexp.MustCompile(`\ntest_key_encoding_info\{db="db11",encoding="[a-z]+",key="beatles_list"\} 1\n`).MatchString(body) {
+		t.Errorf("want metrics to include the encoding of beatles_list, have:\n%s", body)
+	}
+
+	// the key expires in 300 seconds
+	match := regexp.MustCompile(`\ntest_key_ttl_seconds\{db="db11",key="` + regexp.QuoteMeta(keysExpiring[0]) + `"\} (\S+)\n`).FindStringSubmatch(body)
+	if match == nil {
+		t.Fatalf("want metrics to include the TTL of %s, have:\n%s", keysExpiring[0], body)
+	}
+	if ttl, err := strconv.ParseFloat(match[1], 64); err != nil || ttl <= 0 || ttl > 300 {
+		t.Errorf("want TTL of %s between 0 and 300, got %s", keysExpiring[0], match[1])
+	}
+}
+
 func TestKeyValueInvalidDB(t *testing.T) {
 	e, _ := NewRedisExporter(
 		os.Getenv("TEST_REDIS_URI"),
@@ -681,3 +748,71 @@ func TestGetKeysCount(t *testing.T) {
 		}
 	}
 }
+
+func TestSplitCountKeysType(t *testing.T) {
+	for _, tst := range []struct {
+		arg, pattern, keyType string
+	}{
+		{"user:*", "user:*", ""},
+		{"user:*#hash", "user:*", "hash"},
+		{"user:*#*", "user:*", "*"},
+		{"user#1:*#zset", "user#1:*", "zset"},
+		{"user#1*", "user#1*", ""},
+		{"#hash", "#hash", ""},
+	} {
+		pattern, keyType := splitCountKeysType(tst.arg)
+		if pattern != tst.pattern || keyType != tst.keyType {
+			t.Errorf("splitCountKeysType(%s): want %s, %s got %s, %s", tst.arg, tst.pattern, tst.keyType, pattern, keyType)
+		}
+	}
+}
+
+func TestCountKeysByType(t *testing.T) {
+	if os.Getenv("TEST_REDIS_URI") == "" {
+		t.Skipf("TEST_REDIS_URI not set - skipping")
+	}
+	addr := os.Getenv("TEST_REDIS_URI")
+
+	c, err := redis.DialURL(addr)
+	if err != nil {
+		t.Fatalf("Couldn't connect to %#v: %#v", addr, err)
+	}
+	if _, err := c.Do("SELECT", dbNumStr); err != nil {
+		t.Fatalf("Couldn't select database %#v", dbNumStr)
+	}
+
+	fixtures := []keyFixture{
+		{"SET", "count_type_test:string1", []interface{}{"Woohoo!"}},
+		{"SET", "count_type_test:string2", []interface{}{"!oohooW"}},
+		{"HSET", "count_type_test:hash1", []interface{}{"field", "value"}},
+	}
+	createKeyFixtures(t, c, fixtures)
+	defer func() {
+		deleteKeyFixtures(t, c, fixtures)
+		c.Close()
+	}()
+
+	e, _ := NewRedisExporter(addr, Options{
+		Namespace:          "test",
+		Registry:           prometheus.NewRegistry(),
+		CountKeys:          dbNumStrFull + "=count_type_test:*#*," + dbNumStrFull + "=count_type_test:string*#hash," + dbNumStrFull + "=count_type_test:*",
+		CheckKeysBatchSize: 1000,
+	})
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+
+	body := downloadURL(t, ts.URL+"/metrics")
+	for _, want := range []string{
+		`test_keys_count_by_type{db="db11",key="count_type_test:*",type="string"} 2`,
+		`test_keys_count_by_type{db="db11",key="count_type_test:*",type="hash"} 1`,
+		`test_keys_count_by_type{db="db11",key="count_type_test:string*",type="hash"} 0`,
+		`test_keys_count{db="db11",key="count_type_test:*"} 3`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("want metrics to include %s, have:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, `key="count_type_test:*",type="list"`) {
+		t.Errorf("didn't expect a count of lists, have:\n%s", body)
+	}
+}
@@ -1,7 +1,17 @@
 package exporter
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gomodule/redigo/redis"
 	"github.com/prometheus/client_golang/prometheus"
@@ -31,3 +41,273 @@ func (e *Exporter) extractLuaScriptMetrics(ch chan<- prometheus.Metric, c redis.
 	}
 	return nil
 }
+
+// LuaScript is one of the collection scripts of the script directory, its settings are read from
+// comments at the top of the script:
+//
+//	-- prefix: queues
+//	-- db: 2
+//	-- timeout: 500ms
+type LuaScript struct {
+	// file name of the script, used as "script" label
+	Name   string
+	Script []byte
+	// prefix of the names of the metrics returned by the script, defaults to the name of the file without extension
+	Prefix string
+	// database to run the script in, defaults to 0
+	DB int
+	// how long to wait for the result of the script, zero means the connection timeout applies
+	Timeout time.Duration
+}
+
+// ParseLuaScript reads the settings from the comments at the top of the script
+func ParseLuaScript(name string, script []byte) (LuaScript, error) {
+	res := LuaScript{
+		Name:   name,
+		Script: script,
+		Prefix: sanitizeMetricName(strings.TrimSuffix(name, filepath.Ext(name))),
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(script))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "--") {
+			break
+		}
+		frags := strings.SplitN(strings.TrimSpace(strings.TrimPrefix(line, "--")), ":", 2)
+		if len(frags) != 2 {
+			continue
+		}
+		val := strings.TrimSpace(frags[1])
+		var err error
+		switch strings.TrimSpace(frags[0]) {
+		case "prefix":
+			res.Prefix = sanitizeMetricName(val)
+		case "db":
+			if res.DB, err = strconv.Atoi(strings.TrimPrefix(val, "db")); err != nil || res.DB < 0 {
+				return res, fmt.Errorf("invalid db of script %s: %s", name, val)
+			}
+		case "timeout":
+			if res.Timeout, err = time.ParseDuration(val); err != nil {
+				return res, fmt.Errorf("invalid timeout of script %s: %s", name, err)
+			}
+		}
+	}
+	return res, nil
+}
+
+// LoadLuaScripts reads all *.lua files of the directory
+func LoadLuaScripts(dir string) ([]LuaScript, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.lua"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	var res []LuaScript
+	for _, f := range files {
+		script, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		s, err := ParseLuaScript(filepath.Base(f), script)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, s)
+	}
+	log.Debugf("Loaded %d scripts from %s", len(res), dir)
+	return res, nil
+}
+
+// scriptMetric is one metric returned by a collection script, either as JSON
+//
+//	[{"name": "queue_length", "type": "gauge", "help": "Length of the queue", "labels": {"queue": "orders"}, "value": 42}]
+//
+// or as nested tables with field names and values
+//
+//	{{"name", "queue_length", "labels", {"queue", "orders"}, "value", 42}}
+type scriptMetric struct {
+	Name   string            `json:"name"`
+	Type   string            `json:"type"`
+	Help   string            `json:"help"`
+	Labels map[string]string `json:"labels"`
+	Value  interface{}       `json:"value"`
+}
+
+func parseScriptMetrics(reply interface{}) ([]scriptMetric, error) {
+	if s, ok := reply.([]byte); ok {
+		var res []scriptMetric
+		if err := json.Unmarshal(s, &res); err != nil {
+			return nil, fmt.Errorf("couldn't parse script result as JSON: %s", err)
+		}
+		return res, nil
+	}
+
+	entries, err := redis.Values(reply, nil)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]scriptMetric, 0, len(entries))
+	for _, entry := range entries {
+		fields, err := replyToMap(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid metric %v: %s", entry, err)
+		}
+		m := scriptMetric{Value: fields["value"]}
+		m.Name, _ = redis.String(fields["name"], nil)
+		m.Type, _ = redis.String(fields["type"], nil)
+		m.Help, _ = redis.String(fields["help"], nil)
+		if fields["labels"] != nil {
+			if m.Labels, err = redis.StringMap(fields["labels"], nil); err != nil {
+				return nil, fmt.Errorf("invalid labels of metric %s: %s", m.Name, err)
+			}
+		}
+		res = append(res, m)
+	}
+	return res, nil
+}
+
+func scriptMetricValue(v interface{}) (float64, error) {
+	switch val := v.(type) {
+	case float64:
+		return val, nil
+	case string:
+		return strconv.ParseFloat(val, 64)
+	case nil:
+		return 0, fmt.Errorf("missing value")
+	default:
+		return replyToFloat64(val)
+	}
+}
+
+// registerScriptMetrics exports the metrics returned by a script, invalid metrics are skipped so
+// one bad value doesn't cost all the other metrics
+func (e *Exporter) registerScriptMetrics(ch chan<- prometheus.Metric, prefix string, metrics []scriptMetric) {
+	for _, m := range metrics {
+		if m.Name == "" {
+			log.Errorf("Script metric without name: %#v", m)
+			continue
+		}
+
+		valType := prometheus.GaugeValue
+		switch m.Type {
+		case "", "gauge":
+		case "counter":
+			valType = prometheus.CounterValue
+		default:
+			log.Errorf("Unsupported type of script metric %s: %s", m.Name, m.Type)
+			continue
+		}
+
+		val, err := scriptMetricValue(m.Value)
+		if err != nil {
+			log.Errorf("Invalid value of script metric %s: %s", m.Name, err)
+			continue
+		}
+
+		name := prometheus.BuildFQName(e.options.Namespace, prefix, sanitizeMetricName(m.Name))
+		help := m.Help
+		if help == "" {
+			help = name + " metric"
+		}
+		labelNames := make([]string, 0, len(m.Labels))
+		for k := range m.Labels {
+			labelNames = append(labelNames, k)
+		}
+		sort.Strings(labelNames)
+		labelValues := make([]string, 0, len(labelNames))
+		for _, k := range labelNames {
+			labelValues = append(labelValues, m.Labels[k])
+		}
+
+		metric, err := prometheus.NewConstMetric(prometheus.NewDesc(name, help, labelNames, nil), valType, val, labelValues...)
+		if err != nil {
+			log.Errorf("Invalid script metric %s: %s", m.Name, err)
+			continue
+		}
+		ch <- metric
+	}
+}
+
+type luaScriptRunner struct {
+	LuaScript
+	script *redis.Script
+}
+
+func newLuaScriptRunners(scripts []LuaScript) []*luaScriptRunner {
+	res := make([]*luaScriptRunner, 0, len(scripts))
+	for _, s := range scripts {
+		res = append(res, &luaScriptRunner{LuaScript: s, script: redis.NewScript(0, string(s.Script))})
+	}
+	return res
+}
+
+// run executes the script via EVALSHA and only sends the script itself if it isn't cached by Redis yet
+func (s *luaScriptRunner) run(c redis.Conn) (interface{}, error) {
+	if _, err := doRedisCmd(c, "SELECT", s.DB); err != nil {
+		return nil, err
+	}
+
+	reply, err := s.do(c, "EVALSHA", s.script.Hash(), 0)
+	if e, ok := err.(redis.Error); ok && strings.HasPrefix(string(e), "NOSCRIPT ") {
+		reply, err = s.do(c, "EVAL", string(s.Script), 0)
+	}
+	return reply, err
+}
+
+func (s *luaScriptRunner) do(c redis.Conn, cmd string, args ...interface{}) (interface{}, error) {
+	if s.Timeout <= 0 {
+		return doRedisCmd(c, cmd, args...)
+	}
+	return redis.DoWithTimeout(c, s.Timeout, cmd, args...)
+}
+
+// extractLuaScriptsMetrics returns the connection to use for the rest of the scrape, the connection is
+// replaced after a script timed out. The error is only set if the scrape can't continue.
+func (e *Exporter) extractLuaScriptsMetrics(ch chan<- prometheus.Metric, c redis.Conn) (redis.Conn, error) {
+	for _, s := range e.luaScripts {
+		start := time.Now()
+		reply, err := s.run(c)
+		e.registerConstMetricGauge(ch, "script_duration_seconds", time.Since(start).Seconds(), s.Name)
+
+		var metrics []scriptMetric
+		if err == nil {
+			metrics, err = parseScriptMetrics(reply)
+		}
+		if err != nil {
+			log.Errorf("Script %s err: %s", s.Name, err)
+			e.registerConstMetricGauge(ch, "script_success", 0, s.Name)
+
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				// the connection can't be used anymore after a timeout and the script keeps
+				// running inside Redis, every other command gets BUSY until it's killed
+				c.Close()
+				if c, err = e.connectToRedis(); err != nil {
+					return nil, fmt.Errorf("couldn't reconnect after the timeout of script %s: %s", s.Name, err)
+				}
+				if err := killScript(c); err != nil {
+					c.Close()
+					return nil, fmt.Errorf("couldn't kill script %s after its timeout: %s", s.Name, err)
+				}
+			}
+			continue
+		}
+
+		e.registerConstMetricGauge(ch, "script_success", 1, s.Name)
+		e.registerScriptMetrics(ch, s.Prefix, metrics)
+	}
+	return c, nil
+}
+
+// killScript stops the script that's running in Redis, it's fine if it finished already
+func killScript(c redis.Conn) error {
+	_, err := doRedisCmd(c, "SCRIPT", "KILL")
+	if e, ok := err.(redis.Error); ok && strings.HasPrefix(string(e), "NOTBUSY") {
+		return nil
+	}
+	return err
+}
@@ -1,10 +1,13 @@
 package exporter
 
 import (
+	"io/ioutil"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -75,3 +78,143 @@ func TestLuaScript(t *testing.T) {
 		})
 	}
 }
+
+func TestParseLuaScript(t *testing.T) {
+	s, err := ParseLuaScript("my-queues.lua", []byte("-- Collect the queue lengths\n-- prefix: queues\n-- db: 2\n--   timeout: 500ms\n\nreturn {}\n-- db: 3\n"))
+	if err != nil {
+		t.Fatalf("ParseLuaScript() err: %s", err)
+	}
+	if s.Name != "my-queues.lua" || s.Prefix != "queues" || s.DB != 2 || s.Timeout != 500*time.Millisecond {
+		t.Errorf("unexpected script: %#v", s)
+	}
+
+	s, err = ParseLuaScript("my-queues.lua", []byte("return {}"))
+	if err != nil {
+		t.Fatalf("ParseLuaScript() err: %s", err)
+	}
+	if s.Prefix != "my_queues" || s.DB != 0 || s.Timeout != 0 {
+		t.Errorf("unexpected defaults: %#v", s)
+	}
+
+	for _, script := range []string{"-- db: x", "-- db: -1", "-- timeout: 5"} {
+		if _, err := ParseLuaScript("broken.lua", []byte(script)); err == nil {
+			t.Errorf("expected error for script: %s", script)
+		}
+	}
+}
+
+func TestLoadLuaScripts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scripts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, script := range map[string]string{
+		"b.lua":      "return {}",
+		"a.lua":      "-- prefix: first\nreturn {}",
+		"readme.txt": "not a script",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(script), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scripts, err := LoadLuaScripts(dir)
+	if err != nil {
+		t.Fatalf("LoadLuaScripts() err: %s", err)
+	}
+	if len(scripts) != 2 || scripts[0].Name != "a.lua" || scripts[0].Prefix != "first" || scripts[1].Name != "b.lua" {
+		t.Errorf("unexpected scripts: %#v", scripts)
+	}
+}
+
+func TestParseScriptMetrics(t *testing.T) {
+	metrics, err := parseScriptMetrics([]byte(`[{"name": "length", "type": "counter", "labels": {"queue": "orders"}, "value": 42}]`))
+	if err != nil {
+		t.Fatalf("parseScriptMetrics() err: %s", err)
+	}
+	if len(metrics) != 1 || metrics[0].Name != "length" || metrics[0].Type != "counter" || metrics[0].Labels["queue"] != "orders" {
+		t.Errorf("unexpected metrics: %#v", metrics)
+	}
+
+	metrics, err = parseScriptMetrics([]interface{}{
+		[]interface{}{[]byte("name"), []byte("length"), []byte("labels"), []interface{}{[]byte("queue"), []byte("emails")}, []byte("value"), int64(7)},
+	})
+	if err != nil {
+		t.Fatalf("parseScriptMetrics() err: %s", err)
+	}
+	if len(metrics) != 1 || metrics[0].Name != "length" || metrics[0].Labels["queue"] != "emails" {
+		t.Errorf("unexpected metrics: %#v", metrics)
+	}
+
+	for _, reply := range []interface{}{[]byte(`{"name": `), int64(1), []interface{}{[]interface{}{[]byte("name")}}} {
+		if _, err := parseScriptMetrics(reply); err == nil {
+			t.Errorf("expected error for reply: %#v", reply)
+		}
+	}
+}
+
+func TestRegisterScriptMetrics(t *testing.T) {
+	e, _ := NewRedisExporter("", Options{Namespace: "test"})
+	metrics := []scriptMetric{
+		{Name: "length", Labels: map[string]string{"queue": "orders", "app": "shop"}, Value: float64(42)},
+		{Name: "ratio", Type: "gauge", Value: "0.5"},
+		{Name: "no_value"},
+		{Name: "bad_value", Value: "abc"},
+		{Name: "bad_type", Type: "histogram", Value: float64(1)},
+		{Value: float64(1)},
+	}
+
+	got := collectGauges(func(ch chan<- prometheus.Metric) {
+		e.registerScriptMetrics(ch, "queues", metrics)
+	})
+	want := map[string]float64{
+		`queues_length{app="shop",queue="orders"}`: 42,
+		`queues_ratio`: 0.5,
+	}
+	if len(got) != len(want) {
+		t.Errorf("want %d metrics, got: %#v", len(want), got)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("want %s = %f, got: %#v", k, v, got)
+		}
+	}
+}
+
+func TestLuaScripts(t *testing.T) {
+	if os.Getenv("TEST_REDIS_URI") == "" {
+		t.Skipf("TEST_REDIS_URI not set - skipping")
+	}
+
+	var scripts []LuaScript
+	for name, script := range map[string]string{
+		"queues.lua": "-- db: 11\nreturn cjson.encode({{name = 'length', labels = {queue = 'orders'}, value = 3}})",
+		"tables.lua": "return {{'name', 'dbsize', 'value', redis.call('DBSIZE')}}",
+		"broken.lua": "return {",
+	} {
+		s, err := ParseLuaScript(name, []byte(script))
+		if err != nil {
+			t.Fatal(err)
+		}
+		scripts = append(scripts, s)
+	}
+
+	e, _ := NewRedisExporter(os.Getenv("TEST_REDIS_URI"), Options{Namespace: "test", Registry: prometheus.NewRegistry(), LuaScripts: scripts})
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+
+	body := downloadURL(t, ts.URL+"/metrics")
+	for _, want := range []string{
+		`test_queues_length{queue="orders"} 3`,
+		`test_tables_dbsize`,
+		`test_script_success{script="queues.lua"} 1`,
+		`test_script_success{script="broken.lua"} 0`,
+		`test_script_duration_seconds{script="tables.lua"}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("want metrics to include %s, have:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package exporter
+
+import (
+	"regexp"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+var memoryStatsMetricNames = map[string]string{
+	"peak.allocated":                  "memory_stats_peak_allocated_bytes",
+	"total.allocated":                 "memory_stats_total_allocated_bytes",
+	"startup.allocated":               "memory_stats_startup_allocated_bytes",
+	"replication.backlog":             "memory_stats_replication_backlog_bytes",
+	"clients.slaves":                  "memory_stats_clients_slaves_bytes",
+	"clients.normal":                  "memory_stats_clients_normal_bytes",
+	"cluster.links":                   "memory_stats_cluster_links_bytes",
+	"aof.buffer":                      "memory_stats_aof_buffer_bytes",
+	"lua.caches":                      "memory_stats_lua_caches_bytes",
+	"functions.caches":                "memory_stats_functions_caches_bytes",
+	"overhead.total":                  "memory_stats_overhead_total_bytes",
+	"dataset.bytes":                   "memory_stats_dataset_bytes",
+	"dataset.percentage":              "memory_stats_dataset_percentage",
+	"peak.percentage":                 "memory_stats_peak_percentage",
+	"allocator.allocated":             "memory_stats_allocator_allocated_bytes",
+	"allocator.active":                "memory_stats_allocator_active_bytes",
+	"allocator.resident":              "memory_stats_allocator_resident_bytes",
+	"allocator-fragmentation.ratio":   "memory_stats_allocator_fragmentation_ratio",
+	"allocator-fragmentation.bytes":   "memory_stats_allocator_fragmentation_bytes",
+	"allocator.rss-ratio":             "memory_stats_allocator_rss_ratio",
+	"allocator.rss-bytes":             "memory_stats_allocator_rss_bytes",
+	"rss-overhead.ratio":              "memory_stats_rss_overhead_ratio",
+	"rss-overhead.bytes":              "memory_stats_rss_overhead_bytes",
+	"fragmentation":                   "memory_stats_fragmentation_ratio",
+	"fragmentation.bytes":             "memory_stats_fragmentation_bytes",
+	"keys.count":                      "memory_stats_keys_count",
+	"keys.bytes-per-key":              "memory_stats_keys_bytes_per_key",
+	"overhead.db.hashtable.lut":       "memory_stats_db_hashtable_lut_overhead_bytes",
+	"overhead.db.hashtable.rehashing": "memory_stats_db_hashtable_rehashing_overhead_bytes",
+	"db.dict.rehashing.count":         "memory_stats_db_dict_rehashing_count",
+}
+
+var memoryStatsDBMetricNames = map[string]string{
+	"overhead.hashtable.main":    "memory_stats_db_main_overhead_bytes",
+	"overhead.hashtable.expires": "memory_stats_db_expires_overhead_bytes",
+}
+
+// matches the per-db entries, e.g. "db.0" or "overhead.db.0" depending on the Redis version
+var memoryStatsDBKeyRE = regexp.MustCompile(`^(?:overhead\.)?db\.(\d+)$`)
+
+/*
+	MEMORY STATS returns a flat list of field names and values, the per-db entries are nested:
+		 1) "peak.allocated"
+		 2) (integer) 1044584
+		...
+		21) "db.0"
+		22) 1) "overhead.hashtable.main"
+		    2) (integer) 72
+		    3) "overhead.hashtable.expires"
+		    4) (integer) 0
+		...
+		29) "dataset.percentage"
+		30) "8.4"
+*/
+func (e *Exporter) extractMemoryStatsMetrics(ch chan<- prometheus.Metric, c redis.Conn) {
+	reply, err := redis.Values(doRedisCmd(c, "MEMORY", "STATS"))
+	if err != nil {
+		log.Errorf("MEMORY STATS err: %s", err)
+		return
+	}
+	e.handleMemoryStatsReply(ch, reply)
+}
+
+func (e *Exporter) handleMemoryStatsReply(ch chan<- prometheus.Metric, reply []interface{}) {
+	for i := 0; i+1 < len(reply); i += 2 {
+		fieldKey, err := redis.String(reply[i], nil)
+		if err != nil {
+			continue
+		}
+
+		if m := memoryStatsDBKeyRE.FindStringSubmatch(fieldKey); m != nil {
+			e.extractMemoryStatsDBMetrics(ch, "db"+m[1], reply[i+1])
+			continue
+		}
+
+		metricName, ok := memoryStatsMetricNames[fieldKey]
+		if !ok {
+			log.Debugf("MEMORY STATS - skipping unknown field: %s", fieldKey)
+			continue
+		}
+		val, err := replyToFloat64(reply[i+1])
+		if err != nil {
+			log.Debugf("MEMORY STATS - couldn't parse value of %s: %s", fieldKey, err)
+			continue
+		}
+		e.registerConstMetricGauge(ch, metricName, val)
+	}
+}
+
+func (e *Exporter) extractMemoryStatsDBMetrics(ch chan<- prometheus.Metric, dbName string, reply interface{}) {
+	dbStats, err := redis.Values(reply, nil)
+	if err != nil {
+		log.Debugf("MEMORY STATS - couldn't parse stats of %s: %s", dbName, err)
+		return
+	}
+
+	for i := 0; i+1 < len(dbStats); i += 2 {
+		fieldKey, err := redis.String(dbStats[i], nil)
+		if err != nil {
+			continue
+		}
+		metricName, ok := memoryStatsDBMetricNames[fieldKey]
+		if !ok {
+			log.Debugf("MEMORY STATS - skipping unknown field for %s: %s", dbName, fieldKey)
+			continue
+		}
+		if val, err := replyToFloat64(dbStats[i+1]); err == nil {
+			e.registerConstMetricGauge(ch, metricName, val, dbName)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package exporter
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestHandleMemoryStatsReply(t *testing.T) {
+	e, _ := NewRedisExporter("", Options{Namespace: "test"})
+
+	reply := []interface{}{
+		[]byte("peak.allocated"), int64(1044584),
+		[]byte("clients.normal"), int64(20512),
+		[]byte("db.0"), []interface{}{[]byte("overhead.hashtable.main"), int64(72), []byte("overhead.hashtable.expires"), int64(32)},
+		[]byte("overhead.db.3"), []interface{}{[]byte("overhead.hashtable.main"), int64(144)},
+		[]byte("keys.bytes-per-key"), int64(12),
+		[]byte("dataset.percentage"), []byte("8.4"),
+		[]byte("fragmentation"), []byte("1.5"),
+		[]byte("some.future.field"), int64(1),
+	}
+
+	chM := make(chan prometheus.Metric)
+	go func() {
+		e.handleMemoryStatsReply(chM, reply)
+		close(chM)
+	}()
+
+	want := map[string]float64{
+		"memory_stats_peak_allocated_bytes":                1044584,
+		"memory_stats_clients_normal_bytes":                20512,
+		`memory_stats_db_main_overhead_bytes{db="db0"}`:    72,
+		`memory_stats_db_expires_overhead_bytes{db="db0"}`: 32,
+		`memory_stats_db_main_overhead_bytes{db="db3"}`:    144,
+		"memory_stats_keys_bytes_per_key":                  12,
+		"memory_stats_dataset_percentage":                  8.4,
+		"memory_stats_fragmentation_ratio":                 1.5,
+	}
+	for m := range chM {
+		got := &dto.Metric{}
+		m.Write(got)
+
+		name := strings.TrimPrefix(strings.Split(strings.Split(m.Desc().String(), `fqName: "`)[1], `"`)[0], "test_")
+		for _, l := range got.GetLabel() {
+			name += `{` + l.GetName() + `="` + l.GetValue() + `"}`
+		}
+
+		wantVal, ok := want[name]
+		if !ok {
+			t.Errorf("unexpected metric: %s", name)
+			continue
+		}
+		if val := got.GetGauge().GetValue(); val != wantVal {
+			t.Errorf("metric %s: want %f, got %f", name, wantVal, val)
+		}
+		delete(want, name)
+	}
+	for k := range want {
+		t.Errorf("didn't find %s", k)
+	}
+}
+
+func TestMemoryStatsMetrics(t *testing.T) {
+	if os.Getenv("TEST_REDIS_URI") == "" {
+		t.Skipf("TEST_REDIS_URI not set - skipping")
+	}
+
+	for _, inc := range []bool{true, false} {
+		e, _ := NewRedisExporter(os.Getenv("TEST_REDIS_URI"), Options{Namespace: "test", Registry: prometheus.NewRegistry(), InclMemoryStatsMetrics: inc})
+
+		chM := make(chan prometheus.Metric)
+		go func() {
+			e.Collect(chM)
+			close(chM)
+		}()
+
+		found := false
+		for m := range chM {
+			if strings.Contains(m.Desc().String(), "memory_stats_total_allocated_bytes") {
+				found = true
+			}
+		}
+
+		if inc && !found {
+			t.Errorf("memory_stats_total_allocated_bytes was *not* found but expected")
+		} else if !inc && found {
+			t.Errorf("memory_stats_total_allocated_bytes was *found* but *not* expected")
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package exporter
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// parseChannelArg parses a comma separated list of (url encoded) pub/sub channels or channel patterns,
+// unlike keys channels don't belong to a database
+func parseChannelArg(channelsArgString string) (channels []string, err error) {
+	for _, c := range strings.Split(channelsArgString, ",") {
+		if c = strings.TrimSpace(c); c == "" {
+			continue
+		}
+		channel, err := url.QueryUnescape(c)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse channel string: %s", c)
+		}
+		channels = append(channels, channel)
+	}
+	return channels, nil
+}
+
+func (e *Exporter) extractPubSubMetrics(ch chan<- prometheus.Metric, c redis.Conn) {
+	patterns, err := parseChannelArg(e.options.CheckPubSubChannels)
+	if err != nil {
+		log.Errorf("Couldn't parse check-pubsub-channels: %s", err)
+		return
+	}
+	singleChannels, err := parseChannelArg(e.options.CheckSinglePubSubChannels)
+	if err != nil {
+		log.Errorf("Couldn't parse check-single-pubsub-channels: %s", err)
+		return
+	}
+	if len(patterns) == 0 && len(singleChannels) == 0 {
+		return
+	}
+
+	if numPat, err := redis.Int64(doRedisCmd(c, "PUBSUB", "NUMPAT")); err == nil {
+		e.registerConstMetricGauge(ch, "pubsub_numpat", float64(numPat))
+	} else {
+		log.Errorf("PUBSUB NUMPAT err: %s", err)
+	}
+
+	e.extractPubSubChannelMetrics(ch, c, "CHANNELS", "NUMSUB", "pubsub_channel_subscribers", patterns, singleChannels)
+
+	// sharded pub/sub is only available since Redis 7
+	e.extractPubSubChannelMetrics(ch, c, "SHARDCHANNELS", "SHARDNUMSUB", "pubsub_shard_channel_subscribers", patterns, singleChannels)
+}
+
+func (e *Exporter) extractPubSubChannelMetrics(ch chan<- prometheus.Metric, c redis.Conn, listCmd string, numSubCmd string, metric string, patterns []string, singleChannels []string) {
+	args := []interface{}{numSubCmd}
+	seen := map[string]bool{}
+	addChannel := func(channel string) {
+		if !seen[channel] {
+			seen[channel] = true
+			args = append(args, channel)
+		}
+	}
+
+	for _, channel := range singleChannels {
+		addChannel(channel)
+	}
+	for _, pattern := range patterns {
+		channels, err := redis.Strings(doRedisCmd(c, "PUBSUB", listCmd, pattern))
+		if err != nil {
+			log.Debugf("PUBSUB %s err: %s", listCmd, err)
+			return
+		}
+		for _, channel := range channels {
+			addChannel(channel)
+		}
+	}
+	if len(seen) == 0 {
+		return
+	}
+
+	reply, err := redis.Values(doRedisCmd(c, "PUBSUB", args...))
+	if err != nil {
+		log.Debugf("PUBSUB %s err: %s", numSubCmd, err)
+		return
+	}
+	for i := 0; i+1 < len(reply); i += 2 {
+		channel, err := redis.String(reply[i], nil)
+		if err != nil {
+			continue
+		}
+		subscribers, err := redis.Int64(reply[i+1], nil)
+		if err != nil {
+			continue
+		}
+		e.registerConstMetricGauge(ch, metric, float64(subscribers), channel)
+	}
+}
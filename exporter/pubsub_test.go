@@ -0,0 +1,86 @@
+package exporter
+
+import (
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestParseChannelArg(t *testing.T) {
+	for _, tst := range []struct {
+		in      string
+		want    []string
+		wantErr bool
+	}{
+		{in: "", want: nil},
+		{in: "orders.*, events ,", want: []string{"orders.*", "events"}},
+		{in: "with%2Ccomma", want: []string{"with,comma"}},
+		{in: "invalid%zz", wantErr: true},
+	} {
+		got, err := parseChannelArg(tst.in)
+		if (err != nil) != tst.wantErr {
+			t.Errorf("parseChannelArg(%q) - unexpected err: %s", tst.in, err)
+			continue
+		}
+		if strings.Join(got, "|") != strings.Join(tst.want, "|") {
+			t.Errorf("parseChannelArg(%q) - want: %#v, got: %#v", tst.in, tst.want, got)
+		}
+	}
+
+	if _, err := NewRedisExporter("", Options{CheckPubSubChannels: "invalid%zz"}); err == nil {
+		t.Errorf("expected NewRedisExporter() to fail with an invalid check-pubsub-channels")
+	}
+}
+
+func TestPubSubMetrics(t *testing.T) {
+	if os.Getenv("TEST_REDIS_URI") == "" {
+		t.Skipf("TEST_REDIS_URI not set - skipping")
+	}
+	addr := os.Getenv("TEST_REDIS_URI")
+
+	sub, err := redis.DialURL(addr)
+	if err != nil {
+		t.Fatalf("Couldn't connect to %#v: %#v", addr, err)
+	}
+	defer sub.Close()
+
+	psc := redis.PubSubConn{Conn: sub}
+	if err := psc.Subscribe("test_orders.eu", "test_orders.us"); err != nil {
+		t.Fatalf("Couldn't subscribe: %s", err)
+	}
+	if err := psc.PSubscribe("test_pattern.*"); err != nil {
+		t.Fatalf("Couldn't psubscribe: %s", err)
+	}
+	// wait for the confirmations of all three subscriptions
+	for i := 0; i < 3; i++ {
+		if err, ok := psc.ReceiveWithTimeout(time.Second).(error); ok {
+			t.Fatalf("Couldn't subscribe: %s", err)
+		}
+	}
+
+	e, _ := NewRedisExporter(addr, Options{
+		Namespace:                 "test",
+		Registry:                  prometheus.NewRegistry(),
+		CheckPubSubChannels:       "test_orders.*",
+		CheckSinglePubSubChannels: "test_unused",
+	})
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+
+	body := downloadURL(t, ts.URL+"/metrics")
+	for _, want := range []string{
+		`test_pubsub_channel_subscribers{channel="test_orders.eu"} 1`,
+		`test_pubsub_channel_subscribers{channel="test_orders.us"} 1`,
+		`test_pubsub_channel_subscribers{channel="test_unused"} 0`,
+		`test_pubsub_numpat`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("want metrics to include %s, have:\n%s", want, body)
+		}
+	}
+}
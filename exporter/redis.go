@@ -1,6 +1,7 @@
 package exporter
 
 import (
+	"fmt"
 	"net/url"
 	"strings"
 	"time"
@@ -117,3 +118,31 @@ func doRedisCmd(c redis.Conn, cmd string, args ...interface{}) (interface{}, err
 	log.Debugf("c.Do() - done")
 	return res, err
 }
+
+// replyToFloat64 converts integer as well as bulk string replies (e.g. floats returned by Redis) to a float64
+func replyToFloat64(reply interface{}) (float64, error) {
+	if i, ok := reply.(int64); ok {
+		return float64(i), nil
+	}
+	return redis.Float64(reply, nil)
+}
+
+// replyToMap converts a flat list of field names and values, e.g. the reply of FUNCTION STATS, to a map
+func replyToMap(reply interface{}) (map[string]interface{}, error) {
+	values, err := redis.Values(reply, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(values)%2 != 0 {
+		return nil, fmt.Errorf("expected an even number of values, got: %d", len(values))
+	}
+	res := make(map[string]interface{}, len(values)/2)
+	for i := 0; i < len(values); i += 2 {
+		key, err := redis.String(values[i], nil)
+		if err != nil {
+			return nil, err
+		}
+		res[key] = values[i+1]
+	}
+	return res, nil
+}
@@ -146,6 +146,8 @@ func (e *Exporter) extractStreamMetrics(ch chan<- prometheus.Metric, c redis.Con
 	}
 
 	log.Debugf("allStreams: %#v", allStreams)
+	kc := e.newKeyLabelsCollector()
+	defer kc.flush(ch)
 	for _, k := range allStreams {
 		if _, err := doRedisCmd(c, "SELECT", k.db); err != nil {
 			log.Debugf("Couldn't select database '%s' when getting stream info", k.db)
@@ -158,18 +160,18 @@ func (e *Exporter) extractStreamMetrics(ch chan<- prometheus.Metric, c redis.Con
 		}
 		dbLabel := "db" + k.db
 
-		e.registerConstMetricGauge(ch, "stream_length", float64(info.Length), dbLabel, k.key)
-		e.registerConstMetricGauge(ch, "stream_radix_tree_keys", float64(info.RadixTreeKeys), dbLabel, k.key)
-		e.registerConstMetricGauge(ch, "stream_radix_tree_nodes", float64(info.RadixTreeNodes), dbLabel, k.key)
+		kc.add(ch, "stream_length", k.key, float64(info.Length), dbLabel, k.key)
+		kc.add(ch, "stream_radix_tree_keys", k.key, float64(info.RadixTreeKeys), dbLabel, k.key)
+		kc.add(ch, "stream_radix_tree_nodes", k.key, float64(info.RadixTreeNodes), dbLabel, k.key)
 		e.registerConstMetricGauge(ch, "stream_last_generated_id", parseStreamItemId(info.LastGeneratedId), dbLabel, k.key)
-		e.registerConstMetricGauge(ch, "stream_groups", float64(info.Groups), dbLabel, k.key)
+		kc.add(ch, "stream_groups", k.key, float64(info.Groups), dbLabel, k.key)
 
 		for _, g := range info.StreamGroupsInfo {
-			e.registerConstMetricGauge(ch, "stream_group_consumers", float64(g.Consumers), dbLabel, k.key, g.Name)
-			e.registerConstMetricGauge(ch, "stream_group_messages_pending", float64(g.Pending), dbLabel, k.key, g.Name)
+			kc.add(ch, "stream_group_consumers", k.key, float64(g.Consumers), dbLabel, k.key, g.Name)
+			kc.add(ch, "stream_group_messages_pending", k.key, float64(g.Pending), dbLabel, k.key, g.Name)
 			e.registerConstMetricGauge(ch, "stream_group_last_delivered_id", parseStreamItemId(g.LastDeliveredId), dbLabel, k.key, g.Name)
 			for _, c := range g.StreamGroupConsumersInfo {
-				e.registerConstMetricGauge(ch, "stream_group_consumer_messages_pending", float64(c.Pending), dbLabel, k.key, g.Name, c.Name)
+				kc.add(ch, "stream_group_consumer_messages_pending", k.key, float64(c.Pending), dbLabel, k.key, g.Name, c.Name)
 				e.registerConstMetricGauge(ch, "stream_group_consumer_idle_seconds", float64(c.Idle)/1e3, dbLabel, k.key, g.Name, c.Name)
 			}
 		}
@@ -0,0 +1,167 @@
+package exporter
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// dbZSetQueue is a sorted set of check-zset-queues that's scored by due time, in seconds or milliseconds
+type dbZSetQueue struct {
+	dbKeyPair
+	millis bool
+}
+
+// parseZSetQueuesArg parses the check-zset-queues argument, it uses the check-keys format and every key
+// (or pattern) can be followed by "#s" or "#ms" for the unit of the scores, e.g. db0=schedule,db1=delayed:*#ms
+func parseZSetQueuesArg(arg string) ([]dbZSetQueue, error) {
+	keys, err := parseKeyArg(arg)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]dbZSetQueue, 0, len(keys))
+	for _, k := range keys {
+		q := dbZSetQueue{dbKeyPair: k}
+		if idx := strings.LastIndex(k.key, "#"); idx > 0 {
+			switch k.key[idx+1:] {
+			case "ms":
+				q.millis = true
+				q.key = k.key[:idx]
+			case "s":
+				q.key = k.key[:idx]
+			}
+		}
+		res = append(res, q)
+	}
+	return res, nil
+}
+
+type zsetQueueWindow struct {
+	label string
+	d     time.Duration
+}
+
+// parseZSetQueueWindows parses the comma separated durations of zset-queue-windows, negative durations
+// are windows before now, e.g. -1h,-5m,5m
+func parseZSetQueueWindows(s string) ([]zsetQueueWindow, error) {
+	var res []zsetQueueWindow
+	for _, w := range strings.Split(s, ",") {
+		if w = strings.TrimSpace(w); w == "" {
+			continue
+		}
+		d, err := time.ParseDuration(w)
+		if err != nil {
+			return nil, fmt.Errorf("invalid window %q: %s", w, err)
+		}
+		if d == 0 {
+			return nil, fmt.Errorf("invalid window %q: must not be 0", w)
+		}
+		res = append(res, zsetQueueWindow{label: w, d: d})
+	}
+	return res, nil
+}
+
+// zsetQueueScore formats t as score of a queue
+func zsetQueueScore(t time.Time, millis bool) string {
+	if millis {
+		return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+	}
+	return strconv.FormatFloat(float64(t.UnixNano())/float64(time.Second), 'f', 6, 64)
+}
+
+// zsetQueueTime is the inverse of zsetQueueScore
+func zsetQueueTime(score float64, millis bool) time.Time {
+	if millis {
+		score /= 1000
+	}
+	sec, frac := math.Modf(score)
+	return time.Unix(int64(sec), int64(frac*float64(time.Second)))
+}
+
+// redisTime returns the time of the Redis server so the due times are compared to the same clock
+// for all exporters, it falls back to the local time
+func redisTime(c redis.Conn) time.Time {
+	values, err := redis.Int64s(doRedisCmd(c, "TIME"))
+	if err != nil || len(values) != 2 {
+		log.Debugf("TIME err: %s", err)
+		return time.Now()
+	}
+	return time.Unix(values[0], values[1]*int64(time.Microsecond))
+}
+
+func (e *Exporter) extractZSetQueueMetrics(ch chan<- prometheus.Metric, c redis.Conn) {
+	queues, err := parseZSetQueuesArg(e.options.CheckZSetQueues)
+	if err != nil {
+		log.Errorf("Couldn't parse check-zset-queues: %#v", err)
+		return
+	}
+	windows, err := parseZSetQueueWindows(e.options.ZSetQueueWindows)
+	if err != nil {
+		log.Errorf("Couldn't parse zset-queue-windows: %#v", err)
+		return
+	}
+
+	for _, q := range queues {
+		keys, err := getKeysFromPatterns(c, []dbKeyPair{q.dbKeyPair}, e.options.CheckKeysBatchSize)
+		if err != nil {
+			log.Errorf("Error expanding key pattern %s: %s", q.key, err)
+			continue
+		}
+
+		db := q.db
+		if e.options.IsCluster {
+			// Cluster mode only has one db
+			db = "0"
+		} else if _, err := doRedisCmd(c, "SELECT", db); err != nil {
+			log.Errorf("Couldn't select database %#v when checking zset queues.", db)
+			continue
+		}
+		dbLabel := "db" + db
+
+		now := redisTime(c)
+		for _, k := range keys {
+			e.extractZSetQueueKeyMetrics(ch, c, dbLabel, k.key, q.millis, now, windows)
+		}
+	}
+}
+
+func (e *Exporter) extractZSetQueueKeyMetrics(ch chan<- prometheus.Metric, c redis.Conn, dbLabel string, key string, millis bool, now time.Time, windows []zsetQueueWindow) {
+	nowScore := zsetQueueScore(now, millis)
+	overdue, err := redis.Int64(doRedisCmd(c, "ZCOUNT", key, "-inf", nowScore))
+	if err != nil {
+		log.Debugf("ZCOUNT %s err: %s", key, err)
+		return
+	}
+	e.registerConstMetricGauge(ch, "zset_queue_overdue_count", float64(overdue), dbLabel, key)
+
+	age := 0.0
+	if overdue > 0 {
+		values, err := redis.Strings(doRedisCmd(c, "ZRANGE", key, 0, 0, "WITHSCORES"))
+		if err == nil && len(values) == 2 {
+			if score, err := strconv.ParseFloat(values[1], 64); err == nil {
+				age = now.Sub(zsetQueueTime(score, millis)).Seconds()
+			}
+		}
+	}
+	e.registerConstMetricGauge(ch, "zset_queue_oldest_overdue_age_seconds", age, dbLabel, key)
+
+	for _, w := range windows {
+		min, max := "("+nowScore, zsetQueueScore(now.Add(w.d), millis)
+		if w.d < 0 {
+			min, max = zsetQueueScore(now.Add(w.d), millis), nowScore
+		}
+		count, err := redis.Int64(doRedisCmd(c, "ZCOUNT", key, min, max))
+		if err != nil {
+			log.Debugf("ZCOUNT %s err: %s", key, err)
+			continue
+		}
+		e.registerConstMetricGauge(ch, "zset_queue_window_count", float64(count), dbLabel, key, w.label)
+	}
+}
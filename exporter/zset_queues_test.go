@@ -0,0 +1,118 @@
+package exporter
+
+import (
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestParseZSetQueuesArg(t *testing.T) {
+	got, err := parseZSetQueuesArg("db0=schedule,db1=delayed:*#ms,retry#s,jobs#high")
+	if err != nil {
+		t.Fatalf("parseZSetQueuesArg() err: %s", err)
+	}
+	want := []dbZSetQueue{
+		{dbKeyPair: dbKeyPair{db: "0", key: "schedule"}},
+		{dbKeyPair: dbKeyPair{db: "1", key: "delayed:*"}, millis: true},
+		{dbKeyPair: dbKeyPair{db: "0", key: "retry"}},
+		{dbKeyPair: dbKeyPair{db: "0", key: "jobs#high"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want: %#v, got: %#v", want, got)
+	}
+
+	if _, err := parseZSetQueuesArg("dbx=schedule"); err == nil {
+		t.Errorf("expected error for invalid db")
+	}
+}
+
+func TestParseZSetQueueWindows(t *testing.T) {
+	got, err := parseZSetQueueWindows("-1h, -5m,5m,")
+	if err != nil {
+		t.Fatalf("parseZSetQueueWindows() err: %s", err)
+	}
+	want := []zsetQueueWindow{{"-1h", -time.Hour}, {"-5m", -5 * time.Minute}, {"5m", 5 * time.Minute}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want: %#v, got: %#v", want, got)
+	}
+
+	for _, s := range []string{"5", "0s", "soon"} {
+		if _, err := parseZSetQueueWindows(s); err == nil {
+			t.Errorf("expected error for %s", s)
+		}
+	}
+	if _, err := NewRedisExporter("", Options{ZSetQueueWindows: "soon"}); err == nil {
+		t.Errorf("expected error for invalid zset-queue-windows")
+	}
+}
+
+func TestZSetQueueScore(t *testing.T) {
+	now := time.Unix(1700000000, 250*int64(time.Millisecond))
+	if got := zsetQueueScore(now, true); got != "1700000000250" {
+		t.Errorf("want 1700000000250, got %s", got)
+	}
+	if got := zsetQueueScore(now, false); got != "1700000000.250000" {
+		t.Errorf("want 1700000000.250000, got %s", got)
+	}
+	for _, got := range []time.Time{zsetQueueTime(1700000000250, true), zsetQueueTime(1700000000.25, false)} {
+		if got.Sub(now) > time.Microsecond || now.Sub(got) > time.Microsecond {
+			t.Errorf("want %s, got %s", now, got)
+		}
+	}
+}
+
+func TestZSetQueueMetrics(t *testing.T) {
+	if os.Getenv("TEST_REDIS_URI") == "" {
+		t.Skipf("TEST_REDIS_URI not set - skipping")
+	}
+	addr := os.Getenv("TEST_REDIS_URI")
+
+	c, err := redis.DialURL(addr)
+	if err != nil {
+		t.Fatalf("Couldn't connect to %#v: %#v", addr, err)
+	}
+	defer c.Close()
+
+	if _, err := doRedisCmd(c, "SELECT", dbNumStr); err != nil {
+		t.Fatal(err)
+	}
+	now := redisTime(c)
+	if _, err := doRedisCmd(c, "ZADD", "zset_queue_schedule",
+		now.Add(-2*time.Hour).Unix(), "job1",
+		now.Add(-time.Minute).Unix(), "job2",
+		now.Add(2*time.Minute).Unix(), "job3",
+		now.Add(2*time.Hour).Unix(), "job4",
+	); err != nil {
+		t.Fatal(err)
+	}
+	defer doRedisCmd(c, "DEL", "zset_queue_schedule")
+
+	e, _ := NewRedisExporter(addr, Options{
+		Namespace:          "test",
+		Registry:           prometheus.NewRegistry(),
+		CheckZSetQueues:    dbNumStrFull + "=zset_queue_*",
+		ZSetQueueWindows:   "-1h,5m",
+		CheckKeysBatchSize: 1000,
+	})
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+
+	body := downloadURL(t, ts.URL+"/metrics")
+	for _, want := range []string{
+		`test_zset_queue_overdue_count{db="db11",key="zset_queue_schedule"} 2`,
+		// 7200 plus the fraction of the second of now
+		`test_zset_queue_oldest_overdue_age_seconds{db="db11",key="zset_queue_schedule"} 720`,
+		`test_zset_queue_window_count{db="db11",key="zset_queue_schedule",window="-1h"} 1`,
+		`test_zset_queue_window_count{db="db11",key="zset_queue_schedule",window="5m"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("want metrics to include %s, have:\n%s", want, body)
+		}
+	}
+}
@@ -60,12 +60,27 @@ func main() {
 		namespace            = flag.String("namespace", getEnv("REDIS_EXPORTER_NAMESPACE", "redis"), "Namespace for metrics")
 		checkKeys            = flag.String("check-keys", getEnv("REDIS_EXPORTER_CHECK_KEYS", ""), "Comma separated list of key-patterns to export value and length/size, searched for with SCAN")
 		checkSingleKeys      = flag.String("check-single-keys", getEnv("REDIS_EXPORTER_CHECK_SINGLE_KEYS", ""), "Comma separated list of single keys to export value and length/size")
+		checkKeyFields       = flag.String("check-key-fields", getEnv("REDIS_EXPORTER_CHECK_KEY_FIELDS", ""), "Comma separated list of hash key-patterns followed by # and the ; separated fields to export, e.g. db0=stats:*#calls;errors")
+		checkZSetQueues      = flag.String("check-zset-queues", getEnv("REDIS_EXPORTER_CHECK_ZSET_QUEUES", ""), "Comma separated list of sorted set key-patterns scored by due time, optionally followed by #ms for scores in milliseconds, e.g. db0=schedule,db0=retry")
+		zsetQueueWindows     = flag.String("zset-queue-windows", getEnv("REDIS_EXPORTER_ZSET_QUEUE_WINDOWS", ""), "Comma separated list of durations relative to now to count the members of check-zset-queues in, e.g. -1h,-5m,5m")
+		checkKeysDetails     = flag.Bool("check-keys-details", getEnvBool("REDIS_EXPORTER_CHECK_KEYS_DETAILS", false), "Whether to export TTL, memory usage, encoding and idle time of the keys of check-keys and check-single-keys")
+		keyMemUsageSamples   = flag.Int64("key-memory-usage-samples", getEnvInt64("REDIS_EXPORTER_KEY_MEMORY_USAGE_SAMPLES", 5), "Number of nested values sampled by MEMORY USAGE for check-keys-details, 0 samples all of them")
+		keyLabelRegexes      = flag.String("key-label-regexes", getEnv("REDIS_EXPORTER_KEY_LABEL_REGEXES", ""), "Comma separated list of regexes whose named capture groups are added as labels to the metrics of check-keys, count-keys and check-streams, the first matching regex is used")
+		keyLabelsDropKey     = flag.Bool("key-label-regexes-drop-key", getEnvBool("REDIS_EXPORTER_KEY_LABEL_REGEXES_DROP_KEY", false), "Whether to drop the key label of the keys matching key-label-regexes and sum the values of the keys with the same labels")
 		checkKeyGroups       = flag.String("check-key-groups", getEnv("REDIS_EXPORTER_CHECK_KEY_GROUPS", ""), "Comma separated list of lua regex for grouping keys")
 		checkStreams         = flag.String("check-streams", getEnv("REDIS_EXPORTER_CHECK_STREAMS", ""), "Comma separated list of stream-patterns to export info about streams, groups and consumers, searched for with SCAN")
 		checkSingleStreams   = flag.String("check-single-streams", getEnv("REDIS_EXPORTER_CHECK_SINGLE_STREAMS", ""), "Comma separated list of single streams to export info about streams, groups and consumers")
+		checkPubSubChans     = flag.String("check-pubsub-channels", getEnv("REDIS_EXPORTER_CHECK_PUBSUB_CHANNELS", ""), "Comma separated list of pub/sub channel patterns to export the number of subscribers of the matching channels, e.g. orders.*,events")
+		checkSinglePubSub    = flag.String("check-single-pubsub-channels", getEnv("REDIS_EXPORTER_CHECK_SINGLE_PUBSUB_CHANNELS", ""), "Comma separated list of pub/sub channels to export the number of subscribers of, unlike check-pubsub-channels channels without subscribers are exported as well")
 		countKeys            = flag.String("count-keys", getEnv("REDIS_EXPORTER_COUNT_KEYS", ""), "Comma separated list of patterns to count (eg: 'db0=production_*,db3=sessions:*'), searched for with SCAN")
 		checkKeysBatchSize   = flag.Int64("check-keys-batch-size", getEnvInt64("REDIS_EXPORTER_CHECK_KEYS_BATCH_SIZE", 1000), "Approximate number of keys to process in each execution, larger value speeds up scanning.\nWARNING: Still Redis is a single-threaded app, huge COUNT can affect production environment.")
 		scriptPath           = flag.String("script", getEnv("REDIS_EXPORTER_SCRIPT", ""), "Path to Lua Redis script for collecting extra metrics")
+		scriptDir            = flag.String("script-dir", getEnv("REDIS_EXPORTER_SCRIPT_DIR", ""), "Path to a directory of Lua scripts (*.lua) returning typed and labeled metrics")
+		collectFunction      = flag.String("collect-function", getEnv("REDIS_EXPORTER_COLLECT_FUNCTION", ""), "Name of a Redis Function returning typed and labeled metrics, called with FCALL_RO")
+		collectFunctionLib   = flag.String("collect-function-library", getEnv("REDIS_EXPORTER_COLLECT_FUNCTION_LIBRARY", ""), "Path to the library of collect-function, loaded with FUNCTION LOAD REPLACE")
+		collectFunctionPfx   = flag.String("collect-function-prefix", getEnv("REDIS_EXPORTER_COLLECT_FUNCTION_PREFIX", ""), "Prefix of the names of the metrics returned by collect-function, defaults to the name of the function")
+		collectFunctionDB    = flag.Int64("collect-function-db", getEnvInt64("REDIS_EXPORTER_COLLECT_FUNCTION_DB", 0), "Database to call collect-function in")
+		customCommandsFile   = flag.String("custom-commands-file", getEnv("REDIS_EXPORTER_CUSTOM_COMMANDS_FILE", ""), "Path to a JSON file of read-only commands whose replies are exported as metrics")
 		listenAddress        = flag.String("web.listen-address", getEnv("REDIS_EXPORTER_WEB_LISTEN_ADDRESS", ":9121"), "Address to listen on for web interface and telemetry.")
 		metricPath           = flag.String("web.telemetry-path", getEnv("REDIS_EXPORTER_WEB_TELEMETRY_PATH", "/metrics"), "Path under which to expose metrics.")
 		logFormat            = flag.String("log-format", getEnv("REDIS_EXPORTER_LOG_FORMAT", "txt"), "Log format, valid options are txt and json")
@@ -78,18 +93,45 @@ func main() {
 		tlsServerCertFile    = flag.String("tls-server-cert-file", getEnv("REDIS_EXPORTER_TLS_SERVER_CERT_FILE", ""), "Name of the server certificate file (including full path) if the web interface and telemetry should use TLS")
 		tlsServerCaCertFile  = flag.String("tls-server-ca-cert-file", getEnv("REDIS_EXPORTER_TLS_SERVER_CA_CERT_FILE", ""), "Name of the CA certificate file (including full path) if the web interface and telemetry should require TLS client authentication")
 		maxDistinctKeyGroups = flag.Int64("max-distinct-key-groups", getEnvInt64("REDIS_EXPORTER_MAX_DISTINCT_KEY_GROUPS", 100), "The maximum number of distinct key groups with the most memory utilization to present as distinct metrics per database, the leftover key groups will be aggregated in the 'overflow' bucket")
+		keyGroupsBreakdown   = flag.Bool("key-groups-breakdown", getEnvBool("REDIS_EXPORTER_KEY_GROUPS_BREAKDOWN", false), "Whether to break down the key groups of check-key-groups by type, TTL presence and encoding")
+		keyGroupGlobs        = flag.String("key-group-globs", getEnv("REDIS_EXPORTER_KEY_GROUP_GLOBS", ""), "Comma separated list of glob patterns for grouping keys instead of the lua regexes of check-key-groups")
+		keyGroupPrefixDelim  = flag.String("key-group-prefix-delimiter", getEnv("REDIS_EXPORTER_KEY_GROUP_PREFIX_DELIMITER", ""), "Delimiter of the key prefixes that keys not matching key-group-globs are grouped by, e.g. ':'")
+		keyGroupPrefixDepth  = flag.Int64("key-group-prefix-depth", getEnvInt64("REDIS_EXPORTER_KEY_GROUP_PREFIX_DEPTH", 1), "Number of delimited parts of the key prefixes of key-group-prefix-delimiter")
+		keyCountEstSamples   = flag.Int64("key-count-estimate-samples", getEnvInt64("REDIS_EXPORTER_KEY_COUNT_ESTIMATE_SAMPLES", 0), "Number of random keys sampled per database to estimate the counts of count-keys and check-key-groups instead of scanning all keys, 0 disables the estimation")
+		bigKeysTopN          = flag.Int64("big-keys-top-n", getEnvInt64("REDIS_EXPORTER_BIG_KEYS_TOP_N", 10), "Number of biggest keys per database and type to export with check-big-keys")
+		hotKeysTopN          = flag.Int64("hot-keys-top-n", getEnvInt64("REDIS_EXPORTER_HOT_KEYS_TOP_N", 10), "Number of most frequently accessed keys per database to export with check-hot-keys")
+		sampleKeysBudget     = flag.Int64("sample-keys-budget", getEnvInt64("REDIS_EXPORTER_SAMPLE_KEYS_BUDGET", 1000), "Maximum number of keys per database the key sampling collectors (e.g. check-big-keys) look at during one scrape")
+		sampleKeysTimeout    = flag.String("sample-keys-timeout", getEnv("REDIS_EXPORTER_SAMPLE_KEYS_TIMEOUT", "1s"), "Maximum time per database the key sampling collectors (e.g. check-big-keys) spend during one scrape")
+		slotStatsLimit       = flag.Int64("cluster-slot-stats-limit", getEnvInt64("REDIS_EXPORTER_CLUSTER_SLOT_STATS_LIMIT", 10), "Number of top slots per metric (key count, cpu usage, network in/out) to export with export-cluster-slot-stats")
+		aclLogCount          = flag.Int64("acl-log-count", getEnvInt64("REDIS_EXPORTER_ACL_LOG_COUNT", 128), "Number of ACL LOG entries to read during every scrape with export-acl-log")
+		aclLogMaxObjects     = flag.Int64("acl-log-max-objects", getEnvInt64("REDIS_EXPORTER_ACL_LOG_MAX_OBJECTS", 100), "Maximum number of distinct objects (commands, keys or channels) tracked with export-acl-log, further objects are aggregated in the 'overflow' object")
+		aclLogMaxUsernames   = flag.Int64("acl-log-max-usernames", getEnvInt64("REDIS_EXPORTER_ACL_LOG_MAX_USERNAMES", 100), "Maximum number of distinct usernames tracked with export-acl-log, further usernames (e.g. of failed AUTHs) are aggregated in the 'overflow' username")
 		isDebug              = flag.Bool("debug", getEnvBool("REDIS_EXPORTER_DEBUG", false), "Output verbose debug information")
 		setClientName        = flag.Bool("set-client-name", getEnvBool("REDIS_EXPORTER_SET_CLIENT_NAME", true), "Whether to set client name to redis_exporter")
 		isTile38             = flag.Bool("is-tile38", getEnvBool("REDIS_EXPORTER_IS_TILE38", false), "Whether to scrape Tile38 specific metrics")
 		isCluster            = flag.Bool("is-cluster", getEnvBool("REDIS_EXPORTER_IS_CLUSTER", false), "Whether this is a redis cluster (Enable this if you need to fetch key level data on a Redis Cluster).")
+		checkBigKeys         = flag.Bool("check-big-keys", getEnvBool("REDIS_EXPORTER_CHECK_BIG_KEYS", false), "Whether to sample the keyspace for the biggest keys per database and type")
+		checkHotKeys         = flag.Bool("check-hot-keys", getEnvBool("REDIS_EXPORTER_CHECK_HOT_KEYS", false), "Whether to sample the keyspace for the most frequently accessed keys per database, requires an LFU maxmemory-policy")
+		checkTTLDist         = flag.Bool("check-ttl-distribution", getEnvBool("REDIS_EXPORTER_CHECK_TTL_DISTRIBUTION", false), "Whether to sample the keyspace for the distribution of key TTLs per database")
+		ttlDistByKeyGroup    = flag.Bool("ttl-distribution-by-key-group", getEnvBool("REDIS_EXPORTER_TTL_DISTRIBUTION_BY_KEY_GROUP", false), "Whether to break down the TTL distribution by the key groups of check-key-groups")
+		checkKeyIdleTime     = flag.Bool("check-key-idle-time", getEnvBool("REDIS_EXPORTER_CHECK_KEY_IDLE_TIME", false), "Whether to sample the keyspace for the distribution of key idle times per database and key group")
+		exportKeyEvents      = flag.Bool("export-key-events", getEnvBool("REDIS_EXPORTER_EXPORT_KEY_EVENTS", false), "Whether to subscribe to expired, evicted and del key events and count them per database and key group, requires notify-keyspace-events to enable them")
+		keyEventsGroups      = flag.String("key-events-key-groups", getEnv("REDIS_EXPORTER_KEY_EVENTS_KEY_GROUPS", ""), "Comma separated list of regexes for grouping the keys of key events, the group is the concatenation of the capture groups of the first matching regex")
 		exportClientList     = flag.Bool("export-client-list", getEnvBool("REDIS_EXPORTER_EXPORT_CLIENT_LIST", false), "Whether to scrape Client List specific metrics")
+		exportClientListAgg  = flag.Bool("export-client-list-aggregated", getEnvBool("REDIS_EXPORTER_EXPORT_CLIENT_LIST_AGGREGATED", false), "Whether to export Client List metrics aggregated by name, user, library, flags and host instead of one series per connection")
+		exportACLLog         = flag.Bool("export-acl-log", getEnvBool("REDIS_EXPORTER_EXPORT_ACL_LOG", false), "Whether to export the denied commands and authentications of the ACL LOG")
+		exportACLUsers       = flag.Bool("export-acl-users", getEnvBool("REDIS_EXPORTER_EXPORT_ACL_USERS", false), "Whether to export info about the ACL users, e.g. whether they are enabled or use nopass")
 		exportClientPort     = flag.Bool("export-client-port", getEnvBool("REDIS_EXPORTER_EXPORT_CLIENT_PORT", false), "Whether to include the client's port when exporting the client list. Warning: including the port increases the number of metrics generated and will make your Prometheus server take up more memory")
 		showVersion          = flag.Bool("version", false, "Show version information and exit")
 		redisMetricsOnly     = flag.Bool("redis-only-metrics", getEnvBool("REDIS_EXPORTER_REDIS_ONLY_METRICS", false), "Whether to also export go runtime metrics")
 		pingOnConnect        = flag.Bool("ping-on-connect", getEnvBool("REDIS_EXPORTER_PING_ON_CONNECT", false), "Whether to ping the redis instance after connecting")
 		inclConfigMetrics    = flag.Bool("include-config-metrics", getEnvBool("REDIS_EXPORTER_INCL_CONFIG_METRICS", false), "Whether to include all config settings as metrics")
 		redactConfigMetrics  = flag.Bool("redact-config-metrics", getEnvBool("REDIS_EXPORTER_REDACT_CONFIG_METRICS", true), "Whether to redact config settings that include potentially sensitive information like passwords")
+		inclClusterNodes     = flag.Bool("include-cluster-nodes-metrics", getEnvBool("REDIS_EXPORTER_INCL_CLUSTER_NODES_METRICS", false), "Whether to include per-node topology metrics from CLUSTER NODES when scraping a cluster node")
+		exportSlotStats      = flag.Bool("export-cluster-slot-stats", getEnvBool("REDIS_EXPORTER_EXPORT_CLUSTER_SLOT_STATS", false), "Whether to export hot slot metrics from CLUSTER SLOT-STATS (Valkey 8 and newer) when scraping a cluster node")
 		inclSystemMetrics    = flag.Bool("include-system-metrics", getEnvBool("REDIS_EXPORTER_INCL_SYSTEM_METRICS", false), "Whether to include system metrics like e.g. redis_total_system_memory_bytes")
+		inclMemoryStats      = flag.Bool("include-memory-stats-metrics", getEnvBool("REDIS_EXPORTER_INCL_MEMORY_STATS_METRICS", false), "Whether to include the detailed memory metrics reported by MEMORY STATS")
+		inclFunctions        = flag.Bool("include-functions-metrics", getEnvBool("REDIS_EXPORTER_INCL_FUNCTIONS_METRICS", false), "Whether to include the loaded Redis Functions and the FUNCTION STATS metrics (Redis 7+)")
 		skipTLSVerification  = flag.Bool("skip-tls-verification", getEnvBool("REDIS_EXPORTER_SKIP_TLS_VERIFICATION", false), "Whether to to skip TLS verification")
 	)
 	flag.Parse()
@@ -122,6 +164,11 @@ func main() {
 		log.Fatalf("Couldn't parse connection timeout duration, err: %s", err)
 	}
 
+	sampleTo, err := time.ParseDuration(*sampleKeysTimeout)
+	if err != nil {
+		log.Fatalf("Couldn't parse sample keys timeout duration, err: %s", err)
+	}
+
 	passwordMap := make(map[string]string)
 	if *redisPwd == "" && *redisPwdFile != "" {
 		passwordMap, err = exporter.LoadPwdFile(*redisPwdFile)
@@ -137,6 +184,27 @@ func main() {
 		}
 	}
 
+	var luaScripts []exporter.LuaScript
+	if *scriptDir != "" {
+		if luaScripts, err = exporter.LoadLuaScripts(*scriptDir); err != nil {
+			log.Fatalf("Error loading scripts from %s    err: %s", *scriptDir, err)
+		}
+	}
+
+	var collectFunctionLibrary []byte
+	if *collectFunctionLib != "" {
+		if collectFunctionLibrary, err = ioutil.ReadFile(*collectFunctionLib); err != nil {
+			log.Fatalf("Error loading function library %s    err: %s", *collectFunctionLib, err)
+		}
+	}
+
+	var customCommands []exporter.CustomCommand
+	if *customCommandsFile != "" {
+		if customCommands, err = exporter.LoadCustomCommands(*customCommandsFile); err != nil {
+			log.Fatalf("Error loading custom commands from %s    err: %s", *customCommandsFile, err)
+		}
+	}
+
 	registry := prometheus.NewRegistry()
 	if !*redisMetricsOnly {
 		registry = prometheus.DefaultRegisterer.(*prometheus.Registry)
@@ -145,37 +213,79 @@ func main() {
 	exp, err := exporter.NewRedisExporter(
 		*redisAddr,
 		exporter.Options{
-			User:                  *redisUser,
-			Password:              *redisPwd,
-			PasswordMap:           passwordMap,
-			Namespace:             *namespace,
-			ConfigCommandName:     *configCommand,
-			CheckKeys:             *checkKeys,
-			CheckSingleKeys:       *checkSingleKeys,
-			CheckKeysBatchSize:    *checkKeysBatchSize,
-			CheckKeyGroups:        *checkKeyGroups,
-			MaxDistinctKeyGroups:  *maxDistinctKeyGroups,
-			CheckStreams:          *checkStreams,
-			CheckSingleStreams:    *checkSingleStreams,
-			CountKeys:             *countKeys,
-			LuaScript:             ls,
-			InclSystemMetrics:     *inclSystemMetrics,
-			InclConfigMetrics:     *inclConfigMetrics,
-			RedactConfigMetrics:   *redactConfigMetrics,
-			SetClientName:         *setClientName,
-			IsTile38:              *isTile38,
-			IsCluster:             *isCluster,
-			ExportClientList:      *exportClientList,
-			ExportClientsInclPort: *exportClientPort,
-			SkipTLSVerification:   *skipTLSVerification,
-			ClientCertFile:        *tlsClientCertFile,
-			ClientKeyFile:         *tlsClientKeyFile,
-			CaCertFile:            *tlsCaCertFile,
-			ConnectionTimeouts:    to,
-			MetricsPath:           *metricPath,
-			RedisMetricsOnly:      *redisMetricsOnly,
-			PingOnConnect:         *pingOnConnect,
-			Registry:              registry,
+			User:                       *redisUser,
+			Password:                   *redisPwd,
+			PasswordMap:                passwordMap,
+			Namespace:                  *namespace,
+			ConfigCommandName:          *configCommand,
+			CheckKeys:                  *checkKeys,
+			CheckSingleKeys:            *checkSingleKeys,
+			CheckKeyFields:             *checkKeyFields,
+			CheckZSetQueues:            *checkZSetQueues,
+			ZSetQueueWindows:           *zsetQueueWindows,
+			CheckKeysDetails:           *checkKeysDetails,
+			KeyLabelRegexes:            *keyLabelRegexes,
+			KeyLabelRegexesDropKey:     *keyLabelsDropKey,
+			KeyMemoryUsageSamples:      *keyMemUsageSamples,
+			CheckKeysBatchSize:         *checkKeysBatchSize,
+			CheckKeyGroups:             *checkKeyGroups,
+			MaxDistinctKeyGroups:       *maxDistinctKeyGroups,
+			KeyGroupsBreakdown:         *keyGroupsBreakdown,
+			KeyGroupGlobs:              *keyGroupGlobs,
+			KeyGroupPrefixDelimiter:    *keyGroupPrefixDelim,
+			KeyGroupPrefixDepth:        *keyGroupPrefixDepth,
+			KeyCountEstimateSamples:    *keyCountEstSamples,
+			CheckBigKeys:               *checkBigKeys,
+			BigKeysTopN:                *bigKeysTopN,
+			CheckHotKeys:               *checkHotKeys,
+			HotKeysTopN:                *hotKeysTopN,
+			CheckTTLDistribution:       *checkTTLDist,
+			TTLDistributionByKeyGroup:  *ttlDistByKeyGroup,
+			CheckKeyIdleTime:           *checkKeyIdleTime,
+			ExportKeyEvents:            *exportKeyEvents,
+			KeyEventsKeyGroups:         *keyEventsGroups,
+			SampleKeysBudget:           *sampleKeysBudget,
+			SampleKeysTimeout:          sampleTo,
+			CheckStreams:               *checkStreams,
+			CheckSingleStreams:         *checkSingleStreams,
+			CheckPubSubChannels:        *checkPubSubChans,
+			CheckSinglePubSubChannels:  *checkSinglePubSub,
+			CountKeys:                  *countKeys,
+			LuaScript:                  ls,
+			LuaScripts:                 luaScripts,
+			CollectFunction:            *collectFunction,
+			CollectFunctionLibrary:     collectFunctionLibrary,
+			CollectFunctionPrefix:      *collectFunctionPfx,
+			CollectFunctionDB:          *collectFunctionDB,
+			CustomCommands:             customCommands,
+			InclSystemMetrics:          *inclSystemMetrics,
+			InclMemoryStatsMetrics:     *inclMemoryStats,
+			InclFunctionsMetrics:       *inclFunctions,
+			InclConfigMetrics:          *inclConfigMetrics,
+			InclClusterNodesMetrics:    *inclClusterNodes,
+			ExportClusterSlotStats:     *exportSlotStats,
+			ClusterSlotStatsLimit:      *slotStatsLimit,
+			RedactConfigMetrics:        *redactConfigMetrics,
+			SetClientName:              *setClientName,
+			IsTile38:                   *isTile38,
+			IsCluster:                  *isCluster,
+			ExportClientList:           *exportClientList,
+			ExportClientsInclPort:      *exportClientPort,
+			ExportClientListAggregated: *exportClientListAgg,
+			ExportACLLog:               *exportACLLog,
+			ACLLogCount:                *aclLogCount,
+			ACLLogMaxObjects:           *aclLogMaxObjects,
+			ACLLogMaxUsernames:         *aclLogMaxUsernames,
+			ExportACLUsers:             *exportACLUsers,
+			SkipTLSVerification:        *skipTLSVerification,
+			ClientCertFile:             *tlsClientCertFile,
+			ClientKeyFile:              *tlsClientKeyFile,
+			CaCertFile:                 *tlsCaCertFile,
+			ConnectionTimeouts:         to,
+			MetricsPath:                *metricPath,
+			RedisMetricsOnly:           *redisMetricsOnly,
+			PingOnConnect:              *pingOnConnect,
+			Registry:                   registry,
 			BuildInfo: exporter.BuildInfo{
 				Version:   BuildVersion,
 				CommitSha: BuildCommitSha,